@@ -19,6 +19,11 @@ import (
 // sub-types.
 // TODO: ComponentFunc + value marshaling (call exported component functions
 // with primitive / composite WIT values).
+// TODO: a `wasihttp` subpackage exposing `wasi:http/proxy` components as an
+// `http.Handler`, instantiating the component per request. It depends on the
+// ComponentFunc work above to invoke `wasi:http/incoming-handler#handle`, and
+// on Go-defined resources (see the ComponentLinker TODOs) to hand the guest
+// an `incoming-request` and receive its `response-outparam`.
 
 // Component is a compiled WebAssembly component, the binary representation of
 // a component-model artifact. Components are instantiated through a
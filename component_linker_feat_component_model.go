@@ -37,6 +37,10 @@ func (l *ComponentLinker) ptr() *C.wasmtime_component_linker_t {
 
 // Instantiate creates a new [ComponentInstance] of `component` using the
 // imports defined in this linker.
+//
+// TODO: traps during instantiation are returned as an `*Error` which doesn't
+// match sentinels such as `ErrUnreachable` with `errors.Is`, since the C API
+// doesn't expose the trap code of a `wasmtime_error_t`.
 func (l *ComponentLinker) Instantiate(store Storelike, component *Component) (*ComponentInstance, error) {
 	var val C.wasmtime_component_instance_t
 	err := C.wasmtime_component_linker_instantiate(
@@ -79,6 +83,12 @@ func (l *ComponentLinker) DefineUnknownImportsAsTraps(component *Component) erro
 // `wasmtime_component_linker_allow_shadowing` knob is meaningful only once
 // definitions exist, so it will be wired up alongside the host-side API.
 // TODO: WASIp2 / wasi:http integration via `wasmtime_component_linker_add_*`.
+// TODO: an `AddWasiHTTP(http.RoundTripper)` variant which routes
+// `wasi:http/outgoing-handler` requests through Go. This can't be built on
+// the C API's wasi:http support, which always uses Wasmtime's own HTTP
+// client; instead it needs the host-function definitions above plus resource
+// support and component value marshaling so `outgoing-request`,
+// `incoming-response` and their body streams can be implemented in Go.
 
 // Close deallocates this linker's state explicitly.
 //
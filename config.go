@@ -5,7 +5,10 @@ package wasmtime
 // #include <stdlib.h>
 import "C"
 import (
+	"fmt"
+	"math"
 	"runtime"
+	"strconv"
 	"unsafe"
 )
 
@@ -17,8 +20,22 @@ const (
 	StrategyAuto Strategy = C.WASMTIME_STRATEGY_AUTO
 	// StrategyCranelift will force wasmtime to use the Cranelift backend
 	StrategyCranelift Strategy = C.WASMTIME_STRATEGY_CRANELIFT
+	// StrategyWinch will force wasmtime to use the Winch baseline compiler
+	StrategyWinch Strategy = C.WASMTIME_STRATEGY_WINCH
 )
 
+func (strat Strategy) String() string {
+	switch strat {
+	case StrategyAuto:
+		return "auto"
+	case StrategyCranelift:
+		return "cranelift"
+	case StrategyWinch:
+		return "winch"
+	}
+	return "Strategy(" + strconv.Itoa(int(strat)) + ")"
+}
+
 // OptLevel decides what degree of optimization wasmtime will perform on generated machine code
 type OptLevel C.wasmtime_opt_level_t
 
@@ -45,6 +62,27 @@ const (
 // Config holds options used to create an Engine and customize its behavior.
 type Config struct {
 	_ptr *C.wasm_config_t
+
+	// The strategy and target configured so far, which are passed on to the
+	// `Engine` created from this configuration as they can't be read back
+	// out of the C API.
+	strategy Strategy
+	target   string
+
+	// Whether `SetDebugInfo` has been enabled, in which case modules retain
+	// their DWARF debug information.
+	debugInfo bool
+
+	// Sizes of the virtual memory reserved for linear memories, recorded to
+	// validate them before they're passed on to Wasmtime, see `Validate`.
+	memoryReservation          uint64
+	memoryGuardSize            uint64
+	memoryReservationForGrowth uint64
+
+	// The size configured with `SetMaxWasmStack`, if any, recorded to
+	// validate it, see `Validate`.
+	maxWasmStack    int
+	hasMaxWasmStack bool
 }
 
 // NewConfig creates a new `Config` with all default options configured.
@@ -57,20 +95,33 @@ func NewConfig() *Config {
 }
 
 // SetDebugInfo configures whether dwarf debug information for JIT code is enabled
+//
+// This also retains the DWARF debug information of modules compiled with
+// `NewModule`, which `Frame.SourceLocation` resolves source locations with.
 func (cfg *Config) SetDebugInfo(enabled bool) {
 	C.wasmtime_config_debug_info_set(cfg.ptr(), C.bool(enabled))
 	runtime.KeepAlive(cfg)
+	cfg.debugInfo = enabled
 }
 
 // SetMaxWasmStack configures the maximum stack size, in bytes, that JIT code can use.
 // The amount of stack space that wasm takes is always relative to the first invocation of wasm on the stack.
 // Recursive calls with host frames in the middle will all need to fit within this setting.
 // Note that this setting is not interpreted with 100% precision.
+//
+// Sizes which aren't greater than zero are rejected by `Validate`.
 func (cfg *Config) SetMaxWasmStack(size int) {
 	C.wasmtime_config_max_wasm_stack_set(cfg.ptr(), C.size_t(size))
 	runtime.KeepAlive(cfg)
+	cfg.maxWasmStack = size
+	cfg.hasMaxWasmStack = true
 }
 
+// TODO: the size and allocation of the stacks asynchronous WebAssembly
+// executes on, `async_stack_size` and `with_host_stack` in Wasmtime, aren't
+// configurable until asynchronous execution is bound, such as with
+// `wasmtime_func_call_async`, as they'd have no effect without it.
+
 // SetWasmReferenceTypes configures whether the wasm reference types proposal is enabled
 func (cfg *Config) SetWasmReferenceTypes(enabled bool) {
 	C.wasmtime_config_wasm_reference_types_set(cfg.ptr(), C.bool(enabled))
@@ -149,6 +200,23 @@ func (cfg *Config) SetWasmWideArithmetic(enabled bool) {
 	runtime.KeepAlive(cfg)
 }
 
+// SetWasmExceptions configures whether the wasm exception handling proposal is
+// enabled, allowing modules to throw and catch exceptions with `throw` and
+// `try_table`.
+//
+// Tags exported from instances are available through `Extern.Tag`. Note that
+// `exnref` values can't be passed between WebAssembly and the host, and
+// reading one, for example from a global, panics.
+//
+// TODO: exceptions can't yet be thrown, caught or inspected by Go host
+// functions. The C API has no functions to create a tag or get its type, to
+// create an exception object or read its tag and payload, nor to throw or
+// take a pending exception from a store.
+func (cfg *Config) SetWasmExceptions(enabled bool) {
+	C.wasmtime_config_wasm_exceptions_set(cfg.ptr(), C.bool(enabled))
+	runtime.KeepAlive(cfg)
+}
+
 // SetConsumeFuel configures whether fuel is enabled
 func (cfg *Config) SetConsumeFuel(enabled bool) {
 	C.wasmtime_config_consume_fuel_set(cfg.ptr(), C.bool(enabled))
@@ -180,6 +248,99 @@ func (cfg *Config) SetMemoryInitCOWSet(enabled bool) {
 	runtime.KeepAlive(cfg)
 }
 
+// SetMemoryReservation configures the size, in bytes, of the virtual memory
+// reserved up front for each linear memory.
+//
+// Linear memories which fit within their reservation never move when grown,
+// and with a large enough reservation, such as the default 4GiB on 64-bit
+// hosts, bounds checks of 32-bit memories can be elided entirely. Lowering
+// this reduces the address space used by each store, at the cost of bounds
+// checks and of copying memories when they grow beyond their reservation.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.memory_reservation
+func (cfg *Config) SetMemoryReservation(size uint64) {
+	C.wasmtime_config_memory_reservation_set(cfg.ptr(), C.uint64_t(size))
+	runtime.KeepAlive(cfg)
+	cfg.memoryReservation = size
+}
+
+// SetMemoryGuardSize configures the size, in bytes, of the inaccessible guard
+// region placed after each linear memory, which allows Wasmtime to elide bounds
+// checks of accesses with small static offsets.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.memory_guard_size
+func (cfg *Config) SetMemoryGuardSize(size uint64) {
+	C.wasmtime_config_memory_guard_size_set(cfg.ptr(), C.uint64_t(size))
+	runtime.KeepAlive(cfg)
+	cfg.memoryGuardSize = size
+}
+
+// SetMemoryReservationForGrowth configures the size, in bytes, of the extra
+// virtual memory reserved beyond a linear memory's initial size when the
+// memory is moved, so that it can grow in place afterwards.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.memory_reservation_for_growth
+func (cfg *Config) SetMemoryReservationForGrowth(size uint64) {
+	C.wasmtime_config_memory_reservation_for_growth_set(cfg.ptr(), C.uint64_t(size))
+	runtime.KeepAlive(cfg)
+	cfg.memoryReservationForGrowth = size
+}
+
+// SetMemoryMayMove configures whether linear memories may be moved to a new
+// location in memory when they grow beyond their reservation. If not, growing
+// a memory beyond its reservation fails instead.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.memory_may_move
+func (cfg *Config) SetMemoryMayMove(enabled bool) {
+	C.wasmtime_config_memory_may_move_set(cfg.ptr(), C.bool(enabled))
+	runtime.KeepAlive(cfg)
+}
+
+// TODO: `guard_before_linear_memory` isn't configurable, as the C API has no
+// `wasmtime_config_guard_before_linear_memory_set` to bind it with, so
+// Wasmtime's default is always used.
+
+// Validate checks the maximum wasm stack size and the memory reservation and
+// guard size options of this configuration, which Wasmtime would otherwise
+// reject when creating an engine, returning an error describing the first
+// invalid option found.
+//
+// `NewValidatedEngine` returns this error for an invalid configuration, while
+// `NewEngineWithConfig` panics with it. Note that other options, such as
+// enabled proposals, Cranelift flags and targets, aren't checked.
+func (cfg *Config) Validate() error {
+	if cfg.hasMaxWasmStack && cfg.maxWasmStack <= 0 {
+		return fmt.Errorf("max wasm stack size of %d bytes must be greater than zero", cfg.maxWasmStack)
+	}
+
+	// Linear memories are reserved in the host's address space, which on
+	// 32-bit hosts can't hold reservations Wasmtime accepts on 64-bit hosts.
+	if strconv.IntSize == 32 {
+		sizes := []struct {
+			name string
+			size uint64
+		}{
+			{"memory reservation", cfg.memoryReservation},
+			{"memory guard size", cfg.memoryGuardSize},
+			{"memory reservation for growth", cfg.memoryReservationForGrowth},
+		}
+		for _, s := range sizes {
+			if s.size > math.MaxUint32 {
+				return fmt.Errorf("%s of %d bytes exceeds the host's address space", s.name, s.size)
+			}
+		}
+	}
+	if cfg.memoryReservation+cfg.memoryGuardSize < cfg.memoryReservation {
+		return fmt.Errorf("memory reservation of %d bytes and guard size of %d bytes overflow",
+			cfg.memoryReservation, cfg.memoryGuardSize)
+	}
+	return nil
+}
+
 // SetProfiler configures what profiler strategy to use for generated code
 func (cfg *Config) SetProfiler(profiler ProfilingStrategy) {
 	C.wasmtime_config_profiler_set(cfg.ptr(), C.wasmtime_profiling_strategy_t(profiler))
@@ -202,6 +363,10 @@ func (cfg *Config) SetEpochInterruption(enable bool) {
 // detection of SSE4.2 on x86_64 hosts). Native features can be reenabled with
 // the `cranelift_flag_{set,enable}` properties.
 //
+// Code can be compiled for, and executed with, the portable Pulley
+// interpreter by configuring a Pulley target such as the one returned by
+// `PulleyTarget`.
+//
 // For more information see the Rust documentation at
 // https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.config
 func (cfg *Config) SetTarget(target string) error {
@@ -212,6 +377,7 @@ func (cfg *Config) SetTarget(target string) error {
 	if err != nil {
 		return mkError(err)
 	}
+	cfg.target = target
 	return nil
 }
 
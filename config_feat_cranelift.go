@@ -12,6 +12,7 @@ import (
 func (cfg *Config) SetStrategy(strat Strategy) {
 	C.wasmtime_config_strategy_set(cfg.ptr(), C.wasmtime_strategy_t(strat))
 	runtime.KeepAlive(cfg)
+	cfg.strategy = strat
 }
 
 // SetCraneliftDebugVerifier configures whether the cranelift debug verifier will be active when
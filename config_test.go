@@ -24,6 +24,7 @@ func TestConfig(t *testing.T) {
 	NewConfig().SetGCSupport(true)
 	NewConfig().SetWasmComponentModel(true)
 	NewConfig().SetWasmWideArithmetic(true)
+	NewConfig().SetWasmExceptions(true)
 	NewConfig().SetConsumeFuel(true)
 	NewConfig().SetStrategy(StrategyAuto)
 	NewConfig().SetStrategy(StrategyCranelift)
@@ -47,3 +48,79 @@ func TestConfig(t *testing.T) {
 	err = NewConfig().CacheConfigLoad("nonexistent.toml")
 	require.Error(t, err)
 }
+
+func TestConfigMemoryReservation(t *testing.T) {
+	config := NewConfig()
+	config.SetMemoryReservation(1 << 20)
+	config.SetMemoryGuardSize(64 << 10)
+	config.SetMemoryReservationForGrowth(1 << 20)
+	config.SetMemoryMayMove(false)
+	require.NoError(t, config.Validate())
+	store := NewStore(NewEngineWithConfig(config))
+
+	ty, err := NewMemoryType(1, false, 0, false)
+	require.NoError(t, err)
+	mem, err := NewMemory(store, ty)
+	require.NoError(t, err)
+	_, err = mem.Grow(store, 15)
+	require.NoError(t, err)
+	// memories can't move, so they can't grow beyond their reservation
+	_, err = mem.Grow(store, 1)
+	require.Error(t, err)
+}
+
+func TestConfigMemoryReservationInvalid(t *testing.T) {
+	config := NewConfig()
+	config.SetMemoryReservation(^uint64(0) - 1)
+	config.SetMemoryGuardSize(64 << 10)
+	err := config.Validate()
+	require.Error(t, err)
+	engine, engineErr := NewValidatedEngine(config)
+	require.Nil(t, engine)
+	require.Equal(t, err, engineErr)
+	require.PanicsWithValue(t, err, func() {
+		NewEngineWithConfig(config)
+	})
+}
+
+func TestConfigMaxWasmStackInvalid(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		config := NewConfig()
+		config.SetMaxWasmStack(size)
+		require.Error(t, config.Validate())
+		_, err := NewValidatedEngine(config)
+		require.Error(t, err)
+	}
+	config := NewConfig()
+	config.SetMaxWasmStack(1 << 20)
+	require.NoError(t, config.Validate())
+}
+
+func TestConfigWasmExceptions(t *testing.T) {
+	config := NewConfig()
+	config.SetWasmExceptions(true)
+	store := NewStore(NewEngineWithConfig(config))
+	wasm, err := Wat2Wasm(`(module
+	  (tag $e (param i32))
+	  (func $throw (param i32) (throw $e (local.get 0)))
+	  (func (export "catch") (param i32) (result i32)
+	    (block $handler (result i32)
+	      (try_table (catch $e $handler)
+	        (call $throw (local.get 0)))
+	      (i32.const -1)))
+	  (func (export "uncaught") (call $throw (i32.const 1)))
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+
+	ret, err := instance.GetFunc(store, "catch").Call(store, 42)
+	require.NoError(t, err)
+	require.Equal(t, int32(42), ret)
+
+	// exceptions which aren't caught within wasm are returned as errors
+	_, err = instance.GetFunc(store, "uncaught").Call(store)
+	require.Error(t, err)
+}
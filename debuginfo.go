@@ -0,0 +1,237 @@
+package wasmtime
+
+import (
+	"debug/dwarf"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// DWARF debug information found within the custom sections of a wasm module,
+// used to map the offsets of a `Frame` back to source locations.
+//
+// DWARF in wasm describes code addresses as offsets from the start of the
+// contents of the code section, so the location of the code section and its
+// function bodies are recorded alongside the DWARF sections themselves.
+type moduleDebugInfo struct {
+	// the module's name from the `name` section, if any, which frames are
+	// matched to their module with.
+	name      *string
+	sections  map[string][]byte
+	codeStart uint64
+	// module offsets `[start, end)` of each function body defined by the
+	// module, in order.
+	bodies [][2]uint64
+	// number of imported functions, which precede defined functions in the
+	// function index space.
+	importedFuncs int
+
+	once  sync.Once
+	dwarf *dwarf.Data
+}
+
+// Scans the `wasm` binary for DWARF custom sections, returning nil if there
+// aren't any or if the binary can't be parsed.
+func parseModuleDebugInfo(wasm []byte) *moduleDebugInfo {
+	r := &wasmReader{buf: wasm}
+	if _, err := r.bytes(8); err != nil {
+		return nil
+	}
+	info := &moduleDebugInfo{sections: make(map[string][]byte)}
+	for !r.eof() {
+		id, err := r.byte()
+		if err != nil {
+			return nil
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil
+		}
+		start := r.pos
+		contents, err := r.bytes(int(size))
+		if err != nil {
+			return nil
+		}
+		switch id {
+		case 0:
+			section := &wasmReader{buf: contents}
+			name, err := section.name()
+			if err != nil {
+				return nil
+			}
+			if strings.HasPrefix(name, ".debug_") {
+				info.sections[name] = append([]byte(nil), contents[section.pos:]...)
+			} else if name == "name" {
+				info.name = parseModuleName(section)
+			}
+		case 10:
+			info.codeStart = uint64(start)
+			section := &wasmReader{buf: contents}
+			count, err := section.u32()
+			if err != nil {
+				return nil
+			}
+			for i := uint32(0); i < count; i++ {
+				bodySize, err := section.u32()
+				if err != nil {
+					return nil
+				}
+				bodyStart := uint64(start + section.pos)
+				if _, err := section.bytes(int(bodySize)); err != nil {
+					return nil
+				}
+				info.bodies = append(info.bodies, [2]uint64{bodyStart, bodyStart + uint64(bodySize)})
+			}
+		}
+	}
+	if len(info.sections) == 0 {
+		return nil
+	}
+	return info
+}
+
+// Returns the module name from the subsections of a `name` section, or nil if
+// there isn't one.
+func parseModuleName(r *wasmReader) *string {
+	for !r.eof() {
+		id, err := r.byte()
+		if err != nil {
+			return nil
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil
+		}
+		contents, err := r.bytes(int(size))
+		if err != nil {
+			return nil
+		}
+		if id == 0 {
+			name, err := (&wasmReader{buf: contents}).name()
+			if err != nil {
+				return nil
+			}
+			return &name
+		}
+	}
+	return nil
+}
+
+// Lazily parses the DWARF sections, returning nil if they're malformed.
+func (info *moduleDebugInfo) load() *dwarf.Data {
+	info.once.Do(func() {
+		s := info.sections
+		d, err := dwarf.New(s[".debug_abbrev"], s[".debug_aranges"], s[".debug_frame"],
+			s[".debug_info"], s[".debug_line"], s[".debug_pubnames"], s[".debug_ranges"],
+			s[".debug_str"])
+		if err != nil {
+			return
+		}
+		for _, name := range []string{".debug_addr", ".debug_line_str", ".debug_str_offsets", ".debug_rnglists"} {
+			if section, ok := s[name]; ok {
+				if err := d.AddSection(name, section); err != nil {
+					return
+				}
+			}
+		}
+		info.dwarf = d
+	})
+	return info.dwarf
+}
+
+// Returns the source location of the instruction at `moduleOffset` within the
+// function `funcIndex`, if this module defines that function.
+func (info *moduleDebugInfo) sourceLocation(funcIndex uint32, moduleOffset uint64) (string, int, int, bool) {
+	defined := int(funcIndex) - info.importedFuncs
+	if defined < 0 || defined >= len(info.bodies) {
+		return "", 0, 0, false
+	}
+	body := info.bodies[defined]
+	if moduleOffset < body[0] || moduleOffset >= body[1] {
+		return "", 0, 0, false
+	}
+	d := info.load()
+	if d == nil {
+		return "", 0, 0, false
+	}
+	pc := moduleOffset - info.codeStart
+	r := d.Reader()
+	for {
+		entry, err := r.Next()
+		if entry == nil || err != nil {
+			return "", 0, 0, false
+		}
+		if entry.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+		r.SkipChildren()
+		lines, err := d.LineReader(entry)
+		if lines == nil || err != nil {
+			continue
+		}
+		var line dwarf.LineEntry
+		if lines.SeekPC(pc, &line) == nil && line.File != nil {
+			return line.File.Name, line.Line, line.Column, true
+		}
+	}
+}
+
+var errWasmEOF = errors.New("unexpected end of wasm binary")
+
+// A minimal reader of the wasm binary format.
+type wasmReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *wasmReader) eof() bool {
+	return r.pos >= len(r.buf)
+}
+
+func (r *wasmReader) byte() (byte, error) {
+	if r.eof() {
+		return 0, errWasmEOF
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *wasmReader) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(r.buf)-r.pos {
+		return nil, errWasmEOF
+	}
+	ret := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return ret, nil
+}
+
+// Reads an unsigned LEB128-encoded 32-bit integer.
+func (r *wasmReader) u32() (uint32, error) {
+	var ret uint32
+	for shift := 0; shift < 35; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		ret |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return ret, nil
+		}
+	}
+	return 0, errors.New("invalid LEB128 integer in wasm binary")
+}
+
+// Reads a length-prefixed UTF-8 name.
+func (r *wasmReader) name() (string, error) {
+	n, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
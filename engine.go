@@ -4,6 +4,7 @@ package wasmtime
 import "C"
 import (
 	"runtime"
+	"unsafe"
 )
 
 // Engine is an instance of a wasmtime engine which is used to create a `Store`.
@@ -12,6 +13,12 @@ import (
 // and such.
 type Engine struct {
 	_ptr *C.wasm_engine_t
+
+	// The strategy and target of the `Config` this engine was created with.
+	strategy Strategy
+	target   string
+	// Whether the `Config` enabled `SetDebugInfo`.
+	debugInfo bool
 }
 
 // NewEngine creates a new `Engine` with default configuration.
@@ -26,17 +33,43 @@ func NewEngine() *Engine {
 // NewEngineWithConfig creates a new `Engine` with the `Config` provided
 //
 // Note that once a `Config` is passed to this method it cannot be used again.
+//
+// This panics with the error returned by `Config.Validate` if the
+// configuration is invalid, see `NewValidatedEngine` to have it returned
+// instead.
 func NewEngineWithConfig(config *Config) *Engine {
+	engine, err := NewValidatedEngine(config)
+	if err != nil {
+		panic(err)
+	}
+	return engine
+}
+
+// NewValidatedEngine creates a new `Engine` with the `Config` provided, as
+// `NewEngineWithConfig` does, but returns the error returned by
+// `Config.Validate` if the configuration is invalid rather than panicking.
+//
+// Note that once a `Config` is passed to this method it cannot be used again,
+// unless an error is returned.
+func NewValidatedEngine(config *Config) (*Engine, error) {
 	if config.ptr() == nil {
 		panic("config already used")
 	}
-	engine := &Engine{_ptr: C.wasm_engine_new_with_config(config.ptr())}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	engine := &Engine{
+		_ptr:      C.wasm_engine_new_with_config(config.ptr()),
+		strategy:  config.strategy,
+		target:    config.target,
+		debugInfo: config.debugInfo,
+	}
 	runtime.SetFinalizer(config, nil)
 	config._ptr = nil
 	runtime.SetFinalizer(engine, func(engine *Engine) {
 		engine.Close()
 	})
-	return engine
+	return engine, nil
 }
 
 // Close will deallocate this engine's state explicitly.
@@ -91,3 +124,79 @@ func (engine *Engine) IsPulley() bool {
 	runtime.KeepAlive(engine)
 	return bool(ret)
 }
+
+// Strategy returns the compilation strategy that this engine resolved to.
+//
+// Unlike the strategy configured with `Config.SetStrategy` this is never
+// `StrategyAuto`, which resolves to `StrategyCranelift`. Note that code for
+// the Pulley interpreter is also compiled with Cranelift, see `IsPulley`.
+func (engine *Engine) Strategy() Strategy {
+	if engine.strategy == StrategyWinch {
+		return StrategyWinch
+	}
+	return StrategyCranelift
+}
+
+// Target returns the target triple that this engine produces code for, which
+// is either the one configured with `Config.SetTarget` or that of the host.
+func (engine *Engine) Target() string {
+	if engine.target != "" {
+		return engine.target
+	}
+	if engine.IsPulley() {
+		return PulleyTarget()
+	}
+	return hostTarget()
+}
+
+// SupportedTargets returns the target triples that code can be both compiled
+// for, with `Config.SetTarget`, and executed with on this host.
+//
+// This is a fixed list, rather than one queried from Wasmtime, of the host's
+// own target, if Cranelift supports it, followed by the target of the
+// portable Pulley interpreter matching the host, see `PulleyTarget`. Code can
+// also be compiled for other targets but can't then be executed on this host.
+func SupportedTargets() []string {
+	var ret []string
+	if host := hostTarget(); host != "" {
+		ret = append(ret, host)
+	}
+	return append(ret, PulleyTarget())
+}
+
+// PulleyTarget returns the target triple of the Pulley interpreter matching
+// the host's pointer width and endianness, which can be passed to
+// `Config.SetTarget` to execute code with Pulley on this host.
+func PulleyTarget() string {
+	target := "pulley64"
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		target = "pulley32"
+	}
+	var probe uint16 = 1
+	if *(*byte)(unsafe.Pointer(&probe)) == 0 {
+		target += "be"
+	}
+	return target
+}
+
+// Returns the target triple of the host, or an empty string if Cranelift
+// can't generate code for it.
+func hostTarget() string {
+	switch runtime.GOOS + "/" + runtime.GOARCH {
+	case "linux/amd64":
+		return "x86_64-unknown-linux-gnu"
+	case "linux/arm64":
+		return "aarch64-unknown-linux-gnu"
+	case "linux/s390x":
+		return "s390x-unknown-linux-gnu"
+	case "linux/riscv64":
+		return "riscv64gc-unknown-linux-gnu"
+	case "darwin/amd64":
+		return "x86_64-apple-darwin"
+	case "darwin/arm64":
+		return "aarch64-apple-darwin"
+	case "windows/amd64":
+		return "x86_64-pc-windows-gnu"
+	}
+	return ""
+}
@@ -29,3 +29,44 @@ func TestEngineInvalidatesConfig(t *testing.T) {
 		require.Nil(t, engine)
 	}
 }
+
+func TestEngineStrategy(t *testing.T) {
+	engine := NewEngine()
+	require.Equal(t, StrategyCranelift, engine.Strategy())
+	if !engine.IsPulley() {
+		require.Equal(t, SupportedTargets()[0], engine.Target())
+	}
+}
+
+func TestEngineTarget(t *testing.T) {
+	config := NewConfig()
+	require.NoError(t, config.SetTarget(PulleyTarget()))
+	engine := NewEngineWithConfig(config)
+	require.True(t, engine.IsPulley())
+	require.Equal(t, PulleyTarget(), engine.Target())
+	require.Equal(t, StrategyCranelift, engine.Strategy())
+
+	require.Error(t, NewConfig().SetTarget("not-a-target"))
+}
+
+func TestSupportedTargets(t *testing.T) {
+	wasm, err := Wat2Wasm(`(module
+	  (func (export "add") (param i32 i32) (result i32)
+	    (i32.add (local.get 0) (local.get 1)))
+	)`)
+	require.NoError(t, err)
+	for _, target := range SupportedTargets() {
+		config := NewConfig()
+		require.NoError(t, config.SetTarget(target), target)
+		engine := NewEngineWithConfig(config)
+		require.Equal(t, target, engine.Target())
+		module, err := NewModule(engine, wasm)
+		require.NoError(t, err, target)
+		store := NewStore(engine)
+		instance, err := NewInstance(store, module, nil)
+		require.NoError(t, err, target)
+		ret, err := instance.GetFunc(store, "add").Call(store, 1, 2)
+		require.NoError(t, err, target)
+		require.Equal(t, int32(3), ret, target)
+	}
+}
@@ -44,6 +44,21 @@ func (e *Error) ExitStatus() (int32, bool) {
 	return int32(status), bool(ok)
 }
 
+// As allows an `*ExitError` to be extracted from this error with `errors.As`
+// if this was a WASI-defined exit.
+func (e *Error) As(target interface{}) bool {
+	exit, ok := target.(**ExitError)
+	if !ok {
+		return false
+	}
+	status, ok := e.ExitStatus()
+	if !ok {
+		return false
+	}
+	*exit = &ExitError{err: e, code: status}
+	return true
+}
+
 // Close will deallocate this error's state explicitly.
 //
 // For more information see the documentation for engine.Close()
@@ -56,3 +71,50 @@ func (e *Error) Close() {
 	e._ptr = nil
 
 }
+
+// ExitError is the error returned when a WASI program requests to exit, for
+// example through the `proc_exit` function.
+//
+// Functions which execute WebAssembly, such as `Func.Call`, `NewInstance` and
+// `Linker.Instantiate`, return such an exit as an `*Error`, from which an
+// `*ExitError` can be extracted with `errors.As`. The original `*Error` is
+// available through `Unwrap`.
+type ExitError struct {
+	err  *Error
+	code int32
+}
+
+// ExitCode returns the exit code that the WASI program exited with.
+func (e *ExitError) ExitCode() int32 {
+	return e.code
+}
+
+func (e *ExitError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying `*Error` that this exit originated from.
+func (e *ExitError) Unwrap() error {
+	return e.err
+}
+
+// HostError is the error returned when a host function, such as one created
+// with `WrapFunc`, fails with an error which isn't a `*Trap`.
+//
+// The original error returned by the host function is available through
+// `Unwrap`, so it can be inspected with `errors.Is` and `errors.As`, while
+// `Error` additionally describes the wasm backtrace leading to the host
+// function.
+type HostError struct {
+	err   error
+	trace error
+}
+
+func (e *HostError) Error() string {
+	return e.trace.Error()
+}
+
+// Unwrap returns the original error returned by the host function.
+func (e *HostError) Unwrap() error {
+	return e.err
+}
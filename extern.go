@@ -98,6 +98,17 @@ func (e *Extern) Table() *Table {
 	return ret
 }
 
+// Tag returns a Tag if this export is a tag or nil otherwise
+func (e *Extern) Tag() *Tag {
+	ptr := e.ptr()
+	if ptr.kind != C.WASMTIME_EXTERN_TAG {
+		return nil
+	}
+	ret := mkTag(C.go_wasmtime_extern_tag_get(ptr))
+	runtime.KeepAlive(e)
+	return ret
+}
+
 func (e *Extern) AsExtern() C.wasmtime_extern_t {
 	return *e.ptr()
 }
@@ -52,7 +52,8 @@ func NewFunc(
 	ty *FuncType,
 	f func(*Caller, []Val) ([]Val, *Trap),
 ) *Func {
-	idx := insertFuncNew(getDataInStore(store), ty, f)
+	data := getDataInStore(store)
+	idx := insertFuncNew(data, ty, f, "")
 
 	ret := C.wasmtime_func_t{}
 	C.go_func_new(
@@ -64,6 +65,9 @@ func NewFunc(
 	)
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(ty)
+	if data.metrics != nil {
+		data.metrics.hostFuncs[ret] = hostFuncRef{wrap: false, idx: idx}
+	}
 
 	return mkFunc(ret)
 }
@@ -92,10 +96,23 @@ func goTrampolineNew(
 
 	var results []Val
 	var trap *Trap
+	var hookErr error
 	var lastPanic interface{}
 	func() {
 		defer func() { lastPanic = recover() }()
-		results, trap = entry.callback(caller, params)
+		if hookErr = data.invokeCallHook(CallingHost); hookErr != nil {
+			return
+		}
+		if m := data.metrics; m != nil {
+			depth := m.enter(caller, m.hostFuncName(hostFuncRef{wrap: false, idx: int(env)}, entry.name), true)
+			results, trap = entry.callback(caller, params)
+			m.exit(caller, depth)
+		} else {
+			results, trap = entry.callback(caller, params)
+		}
+		if hookErr = data.invokeCallHook(ReturningFromHost); hookErr != nil {
+			return
+		}
 		if trap != nil {
 			if trap._ptr == nil {
 				panic("returned an already-returned trap")
@@ -117,6 +134,9 @@ func goTrampolineNew(
 		runtime.SetFinalizer(trap, nil)
 		return trap.ptr()
 	}
+	if hookErr != nil {
+		return data.hostTrap(hookErr)
+	}
 	if trap != nil {
 		runtime.SetFinalizer(trap, nil)
 		ret := trap.ptr()
@@ -156,9 +176,11 @@ func goTrampolineNew(
 //
 // The Go function may return any number of values. It can return any number of
 // primitive wasm values (integers/floats), and the last return value may
-// optionally be `*Trap`. If a `*Trap` returned is `nil` then the other values
-// are returned from the wasm function. Otherwise the `*Trap` is returned and
-// it's considered as if the host function trapped.
+// optionally be `*Trap` or `error`. If a `*Trap` or `error` returned is `nil`
+// then the other values are returned from the wasm function. Otherwise it's
+// considered as if the host function trapped. A `*Trap` is returned as-is,
+// while any other error is returned from the original invocation of
+// WebAssembly as a `*HostError` wrapping it.
 //
 // If the function `f` panics then the panic will be propagated to the caller.
 func WrapFunc(
@@ -167,7 +189,8 @@ func WrapFunc(
 ) *Func {
 	val := reflect.ValueOf(f)
 	wasmTy := inferFuncType(val)
-	idx := insertFuncWrap(getDataInStore(store), val)
+	data := getDataInStore(store)
+	idx := insertFuncWrap(data, val, "")
 
 	ret := C.wasmtime_func_t{}
 	C.go_func_new(
@@ -179,6 +202,9 @@ func WrapFunc(
 	)
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(wasmTy)
+	if data.metrics != nil {
+		data.metrics.hostFuncs[ret] = hostFuncRef{wrap: true, idx: idx}
+	}
 	return mkFunc(ret)
 }
 
@@ -200,13 +226,13 @@ func inferFuncType(val reflect.Value) *FuncType {
 		}
 	}
 
-	// Then infer the result types, where a final `*Trap` result value is
-	// also special.
+	// Then infer the result types, where a final `*Trap` or `error` result
+	// value is also special.
 	results := make([]*ValType, 0, ty.NumOut())
 	var trap *Trap
 	for i := 0; i < ty.NumOut(); i++ {
 		resultTy := ty.Out(i)
-		if i == ty.NumOut()-1 && resultTy == reflect.TypeOf(trap) {
+		if i == ty.NumOut()-1 && (resultTy == reflect.TypeOf(trap) || resultTy == errorType) {
 			continue
 		}
 		results = append(results, typeToValType(resultTy))
@@ -272,10 +298,21 @@ func goTrampolineWrap(
 	// Invoke the function, catching any panics to propagate later. Panics
 	// result in immediately returning a trap.
 	var results []reflect.Value
+	var hookErr error
 	var lastPanic interface{}
 	func() {
 		defer func() { lastPanic = recover() }()
-		results = entry.callback.Call(params)
+		if hookErr = data.invokeCallHook(CallingHost); hookErr != nil {
+			return
+		}
+		if m := data.metrics; m != nil {
+			depth := m.enter(caller, m.hostFuncName(hostFuncRef{wrap: true, idx: int(env)}, entry.name), true)
+			results = entry.callback.Call(params)
+			m.exit(caller, depth)
+		} else {
+			results = entry.callback.Call(params)
+		}
+		hookErr = data.invokeCallHook(ReturningFromHost)
 	}()
 	if lastPanic != nil {
 		data.lastPanic = lastPanic
@@ -283,11 +320,20 @@ func goTrampolineWrap(
 		runtime.SetFinalizer(trap, nil)
 		return trap.ptr()
 	}
+	if hookErr != nil {
+		return data.hostTrap(hookErr)
+	}
 
 	// And now we write all the results into memory depending on the type
 	// of value that was returned.
 	base = unsafe.Pointer(resultsPtr)
-	for _, result := range results {
+	for i, result := range results {
+		if ty.Out(i) == errorType {
+			if result.IsNil() {
+				continue
+			}
+			return data.hostTrap(result.Interface().(error))
+		}
 		ptr := (*C.wasmtime_val_t)(base)
 		switch val := result.Interface().(type) {
 		case int32:
@@ -320,6 +366,28 @@ func goTrampolineWrap(
 	return nil
 }
 
+// Converts an error produced by a host function into the trap to return to
+// WebAssembly. Errors other than a `*Trap` are recorded so that they're
+// returned from `enterWasm` as a `*HostError`.
+func (data *storeData) hostTrap(err error) *C.wasm_trap_t {
+	if trap, ok := err.(*Trap); ok {
+		if trap == nil {
+			return nil
+		}
+		runtime.SetFinalizer(trap, nil)
+		ret := trap._ptr
+		trap._ptr = nil
+		if ret == nil {
+			data.lastPanic = "cannot return trap twice"
+		}
+		return ret
+	}
+	data.lastError = err
+	trap := NewTrap(err.Error())
+	runtime.SetFinalizer(trap, nil)
+	return trap.ptr()
+}
+
 func mkFunc(val C.wasmtime_func_t) *Func {
 	return &Func{val}
 }
@@ -360,7 +428,9 @@ func (f *Func) Type(store Storelike) *FuncType {
 //
 // 2. If this function invocation traps, then the returned `interface{}` value
 // will be `nil` and a non-`nil` `*Trap` will be returned with information
-// about the trap that happened.
+// about the trap that happened. If a WASI program requested to exit then the
+// returned error is an `*Error`, from which an `*ExitError` carrying its exit
+// code can be extracted with `errors.As`.
 //
 // 3. If a panic in Go ends up happening somewhere, then this function will
 // panic.
@@ -419,7 +489,7 @@ func (f *Func) Call(store Storelike, args ...interface{}) (interface{}, error) {
 
 	resultVals := make([]C.wasmtime_val_t, len(ty.Results()))
 
-	err := enterWasm(store, func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
+	err := enterWasm(store, &f.val, func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
 		var paramsPtr *C.wasmtime_val_t
 		if len(paramVals) > 0 {
 			paramsPtr = (*C.wasmtime_val_t)(unsafe.Pointer(&paramVals[0]))
@@ -469,6 +539,12 @@ func (f *Func) AsExtern() C.wasmtime_extern_t {
 	return ret
 }
 
+// TODO: add `Caller.Backtrace() []*Frame` returning the wasm frames which led
+// to a host call, mirroring Rust's `WasmBacktrace::capture`. The C API
+// currently only exposes backtraces after the fact, through `wasm_trap_trace`
+// and `wasmtime_error_wasm_trace`, so there's nothing to bind this to while a
+// host function is running.
+
 // GetExport gets an exported item from the caller's module.
 //
 // May return `nil` if the export doesn't exist, if it's not a memory, if there isn't
@@ -517,16 +593,33 @@ func (c *Caller) Data() interface{} {
 // WebAssembly. This will also automatically propagate panics that happen within
 // Go from one end back to this original invocation point.
 //
-// The `store` object is the context being used for the invocation, and `wasm`
-// is the closure which will internally execute WebAssembly. A trap pointer is
+// The `store` object is the context being used for the invocation, `callee` is
+// the function being invoked, or nil for instantiation, and `wasm` is the
+// closure which will internally execute WebAssembly. A trap pointer is
 // provided to the closure and it's expected that the closure returns an error.
-func enterWasm(store Storelike, wasm func(**C.wasm_trap_t) *C.wasmtime_error_t) error {
+func enterWasm(store Storelike, callee *C.wasmtime_func_t, wasm func(**C.wasm_trap_t) *C.wasmtime_error_t) error {
 	// Load the internal `storeData` that our `store` references, which is
 	// used for handling panics which we are going to use here.
 	data := getDataInStore(store)
 
+	// Errors of host functions, and epoch interrupts, recorded outside of a
+	// call into wasm didn't originate from this call, so they're discarded.
+	data.lastError = nil
+	data.epochInterrupted = false
+
+	if err := data.invokeCallHook(CallingWasm); err != nil {
+		return err
+	}
+
 	var trap *C.wasm_trap_t
-	err := wasm(&trap)
+	var err *C.wasmtime_error_t
+	if m := data.metrics; m != nil {
+		depth := m.enter(store, m.wasmFuncName(callee), false)
+		err = wasm(&trap)
+		m.exit(store, depth)
+	} else {
+		err = wasm(&trap)
+	}
 
 	// Take ownership of any returned values to ensure we properly run
 	// destructors for them.
@@ -534,10 +627,15 @@ func enterWasm(store Storelike, wasm func(**C.wasm_trap_t) *C.wasmtime_error_t)
 	var wrappedError error
 	if trap != nil {
 		wrappedTrap = mkTrap(trap)
+		wrappedTrap.debugModules = data.debugModules
 	}
 	if err != nil {
 		wrappedError = mkError(err)
 	}
+	lastError := data.lastError
+	data.lastError = nil
+	epochInterrupted := data.epochInterrupted
+	data.epochInterrupted = false
 
 	// Check to see if wasm panicked, and if it did then we need to
 	// propagate that. Note that this happens after we take ownership of
@@ -550,8 +648,34 @@ func enterWasm(store Storelike, wasm func(**C.wasm_trap_t) *C.wasmtime_error_t)
 
 	// If there wasn't a panic then we determine whether to return the trap
 	// or the error.
+	var ret error = wrappedError
 	if wrappedTrap != nil {
-		return wrappedTrap
+		ret = wrappedTrap
+	}
+
+	// A deadline reached after `SetEpochDeadlineCallback(nil)` interrupts
+	// execution, as it would had no callback ever been configured, with the
+	// backtrace of the error that interrupt was raised as.
+	if epochInterrupted && wrappedTrap == nil && wrappedError != nil {
+		interrupt := NewTrapWithCode(Interrupt)
+		interrupt.debugModules = data.debugModules
+		interrupt.trace, _ = wrappedError.(*Error)
+		ret = interrupt
+	}
+
+	// If a host function failed with an error then that's what the trap or
+	// error here originated from, so return the original error with the
+	// trap's description of where it happened.
+	if lastError != nil && ret != nil {
+		ret = &HostError{err: lastError, trace: ret}
+	}
+
+	// An error from the hook takes precedence over the result of the call,
+	// as it does in Wasmtime itself.
+	if err := data.invokeCallHook(ReturningFromWasm); err != nil {
+		return err
 	}
-	return wrappedError
+	return ret
 }
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
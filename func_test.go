@@ -1,6 +1,8 @@
 package wasmtime
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -247,6 +249,100 @@ func TestFuncWrapMultiRetWithTrap(t *testing.T) {
 	require.NoError(t, err)
 }
 
+type customHostError struct {
+	value int32
+}
+
+func (e *customHostError) Error() string {
+	return fmt.Sprintf("custom error %d", e.value)
+}
+
+func TestFuncWrapRetGoError(t *testing.T) {
+	store := NewStore(NewEngine())
+	errSentinel := errors.New("sentinel")
+	f := WrapFunc(store, func(c *Caller) (int32, error) {
+		return 0, fmt.Errorf("wrapped: %w", errSentinel)
+	})
+	require.Len(t, f.Type(store).Results(), 1)
+	_, err := f.Call(store)
+	require.ErrorIs(t, err, errSentinel)
+	require.IsType(t, &HostError{}, err)
+	require.Contains(t, err.Error(), "wrapped: sentinel")
+
+	f = WrapFunc(store, func(c *Caller) (int32, error) {
+		return 3, nil
+	})
+	result, err := f.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), result)
+
+	f = WrapFunc(store, func(c *Caller) error {
+		return NewTrap("x")
+	})
+	_, err = f.Call(store)
+	require.IsType(t, &Error{}, err)
+	require.Equal(t, "x", err.Error())
+}
+
+func TestFuncWrapRetGoErrorBacktrace(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`(module
+	  (import "" "f" (func $f))
+	  (func $outer (export "outer") call $f)
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	f := WrapFunc(store, func() error {
+		return &customHostError{value: 7}
+	})
+	instance, err := NewInstance(store, module, []AsExtern{f})
+	require.NoError(t, err)
+
+	_, err = instance.GetFunc(store, "outer").Call(store)
+	var custom *customHostError
+	require.True(t, errors.As(err, &custom))
+	require.Equal(t, int32(7), custom.value)
+	require.Contains(t, err.Error(), "outer")
+	require.Contains(t, err.Error(), "custom error 7")
+
+	// subsequent calls aren't affected by the previous error
+	_, err = f.Call(store)
+	require.True(t, errors.As(err, &custom))
+}
+
+func TestFuncWrapRetGoErrorOutsideCall(t *testing.T) {
+	store := NewStore(NewEngine())
+	linker := NewLinker(store.Engine)
+	errSentinel := errors.New("sentinel")
+	require.NoError(t, linker.FuncWrap("env", "fail", func() error {
+		return errSentinel
+	}))
+
+	// A reactor's initialization runs outside of any call into wasm made by
+	// this package, so its host error isn't returned as a `HostError`.
+	wasm, err := Wat2Wasm(`(module
+	  (import "env" "fail" (func $fail))
+	  (func (export "_initialize") call $fail)
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	require.Error(t, linker.DefineModule(store, "reactor", module))
+
+	// That error mustn't be attributed to an unrelated failure afterwards.
+	wasm, err = Wat2Wasm(`(module (func (export "run") unreachable))`)
+	require.NoError(t, err)
+	module, err = NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "run").Call(store)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, errSentinel)
+	require.IsType(t, &Trap{}, err)
+}
+
 func TestFuncWrapPanic(t *testing.T) {
 	store := NewStore(NewEngine())
 	f := WrapFunc(store, func() { panic("x") })
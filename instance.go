@@ -26,8 +26,13 @@ func NewInstance(store Storelike, module *Module, imports []AsExtern) (*Instance
 	for i, imp := range imports {
 		importsRaw[i] = imp.AsExtern()
 	}
+	data := getDataInStore(store)
+	data.useModule(module)
+	if data.metrics != nil {
+		data.metrics.recordImports(module, imports)
+	}
 	var val C.wasmtime_instance_t
-	err := enterWasm(store, func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
+	err := enterWasm(store, nil, func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
 		var imports *C.wasmtime_extern_t
 		if len(importsRaw) > 0 {
 			imports = (*C.wasmtime_extern_t)(unsafe.Pointer(&importsRaw[0]))
@@ -48,7 +53,11 @@ func NewInstance(store Storelike, module *Module, imports []AsExtern) (*Instance
 	if err != nil {
 		return nil, err
 	}
-	return mkInstance(val), nil
+	instance := mkInstance(val)
+	if data.metrics != nil {
+		data.metrics.recordInstance(store, instance)
+	}
+	return instance, nil
 }
 
 func mkInstance(val C.wasmtime_instance_t) *Instance {
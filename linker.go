@@ -4,8 +4,10 @@ package wasmtime
 // #include "shims.h"
 import "C"
 import (
+	"fmt"
 	"reflect"
 	"runtime"
+	"sync/atomic"
 )
 
 // Linker implements a wasmtime Linking module, which can link instantiated modules together.
@@ -14,11 +16,57 @@ import (
 type Linker struct {
 	_ptr   *C.wasmtime_linker_t
 	Engine *Engine
+
+	// The names defined in this linker in the order they were first defined,
+	// as the C API has no means of listing them.
+	defined map[ImportKey]struct{}
+	keys    []ImportKey
+
+	// The store-independent functions defined with `FuncNew` and `FuncWrap`,
+	// which are defined again under another name by `Alias`.
+	funcs map[ImportKey]linkerFunc
+
+	// The items defined with `DefineGlobalTemplate` and
+	// `DefineMemoryTemplate`, which are created within each store.
+	templates map[ImportKey]*linkerTemplate
+
+	// Whether shadowing is allowed, mirroring the C linker's setting for
+	// templates which aren't defined within it.
+	shadowing bool
+
+	// A unique identifier of this linker, and a counter of the changes made
+	// to it, which identify the clones of it cached within stores for its
+	// templates.
+	id         uint64
+	generation uint64
+}
+
+var gLinkerID uint64
+
+// A function defined with either `FuncNew`, in which case `ty` and `new` are
+// set, or with `FuncWrap`, in which case `wrap` is set.
+type linkerFunc struct {
+	ty   *FuncType
+	new  func(*Caller, []Val) ([]Val, *Trap)
+	wrap interface{}
+}
+
+// ImportKey is the module and name an item is defined as within a `Linker`.
+type ImportKey struct {
+	Module string
+	Name   string
 }
 
 func NewLinker(engine *Engine) *Linker {
 	ptr := C.wasmtime_linker_new(engine.ptr())
-	linker := &Linker{_ptr: ptr, Engine: engine}
+	linker := &Linker{
+		_ptr:      ptr,
+		Engine:    engine,
+		defined:   make(map[ImportKey]struct{}),
+		funcs:     make(map[ImportKey]linkerFunc),
+		templates: make(map[ImportKey]*linkerTemplate),
+		id:        atomic.AddUint64(&gLinkerID, 1),
+	}
 	runtime.SetFinalizer(linker, func(linker *Linker) {
 		linker.Close()
 	})
@@ -52,11 +100,21 @@ func (l *Linker) Close() {
 func (l *Linker) AllowShadowing(allow bool) {
 	C.wasmtime_linker_allow_shadowing(l.ptr(), C.bool(allow))
 	runtime.KeepAlive(l)
+	l.shadowing = allow
+	l.generation++
 }
 
 // Define defines a new item in this linker with the given module/name pair. Returns
 // an error if shadowing is disallowed and the module/name is already defined.
 func (l *Linker) Define(store Storelike, module, name string, item AsExtern) error {
+	if err := l.checkTemplates(module, name); err != nil {
+		return err
+	}
+	if f, ok := item.(*Func); ok {
+		if m := getDataInStore(store).metrics; m != nil {
+			m.nameFunc(f, module+"."+name)
+		}
+	}
 	extern := item.AsExtern()
 	err := C.wasmtime_linker_define(
 		l.ptr(),
@@ -73,6 +131,7 @@ func (l *Linker) Define(store Storelike, module, name string, item AsExtern) err
 	runtime.KeepAlive(item)
 	runtime.KeepAlive(store)
 	if err == nil {
+		l.record(module, name)
 		return nil
 	}
 
@@ -95,7 +154,10 @@ func (l *Linker) DefineFunc(store Storelike, module, name string, f interface{})
 //
 // Returns an error if shadowing is disabled and the name is already defined.
 func (l *Linker) FuncNew(module, name string, ty *FuncType, f func(*Caller, []Val) ([]Val, *Trap)) error {
-	idx := insertFuncNew(nil, ty, f)
+	if err := l.checkTemplates(module, name); err != nil {
+		return err
+	}
+	idx := insertFuncNew(nil, ty, f, module+"."+name)
 	err := C.go_linker_define_func(
 		l.ptr(),
 		C._GoStringPtr(module),
@@ -111,6 +173,8 @@ func (l *Linker) FuncNew(module, name string, ty *FuncType, f func(*Caller, []Va
 	runtime.KeepAlive(name)
 	runtime.KeepAlive(ty)
 	if err == nil {
+		l.record(module, name)
+		l.funcs[ImportKey{module, name}] = linkerFunc{ty: ty, new: f}
 		return nil
 	}
 
@@ -126,9 +190,12 @@ func (l *Linker) FuncNew(module, name string, ty *FuncType, f func(*Caller, []Va
 //
 // Returns an error if shadowing is disabled and the name is already defined.
 func (l *Linker) FuncWrap(module, name string, f interface{}) error {
+	if err := l.checkTemplates(module, name); err != nil {
+		return err
+	}
 	val := reflect.ValueOf(f)
 	ty := inferFuncType(val)
-	idx := insertFuncWrap(nil, val)
+	idx := insertFuncWrap(nil, val, module+"."+name)
 	err := C.go_linker_define_func(
 		l.ptr(),
 		C._GoStringPtr(module),
@@ -144,16 +211,117 @@ func (l *Linker) FuncWrap(module, name string, f interface{}) error {
 	runtime.KeepAlive(name)
 	runtime.KeepAlive(ty)
 	if err == nil {
+		l.record(module, name)
+		l.funcs[ImportKey{module, name}] = linkerFunc{wrap: f}
 		return nil
 	}
 
 	return mkError(err)
 }
 
+// Alias defines the item named `module`/`name` in this linker again as
+// `asModule`/`asName`.
+//
+// Only items this linker holds independently of a store can be aliased, which
+// are functions defined with `FuncNew` or `FuncWrap` and templates defined
+// with `DefineGlobalTemplate` or `DefineMemoryTemplate`. Notably the functions
+// defined by `DefineWasi` can't be aliased, as the C API only defines them
+// under `wasi_snapshot_preview1`.
+//
+// Unlike Wasmtime's own `alias`, which the C API doesn't expose, a function
+// is aliased by registering its Go implementation again with `FuncNew` or
+// `FuncWrap`. Within a store the alias is then a distinct function from the
+// original, with a different `funcref` identity, although it behaves the
+// same. An aliased template refers to the same item within each store.
+//
+// An error is returned if the item can't be aliased, if it isn't defined, or
+// if shadowing is disabled and `asModule`/`asName` is already defined.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Linker.html#method.alias
+func (l *Linker) Alias(module, name, asModule, asName string) error {
+	define, err := l.aliasable(ImportKey{module, name})
+	if err != nil {
+		return err
+	}
+	return define(asModule, asName)
+}
+
+// AliasModule defines every item in this linker defined under `module` again
+// under `asModule`, keeping their names.
+//
+// As with `Alias` only items held independently of a store can be aliased,
+// and functions are registered again rather than sharing their identity.
+// If any item in `module` can't be aliased, or if shadowing is disabled and
+// any of their names is already defined under `asModule`, then an error is
+// returned and nothing is defined.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Linker.html#method.alias_module
+func (l *Linker) AliasModule(module, asModule string) error {
+	var names []string
+	var defines []func(module, name string) error
+	for _, key := range l.keys {
+		if key.Module != module {
+			continue
+		}
+		define, err := l.aliasable(key)
+		if err != nil {
+			return err
+		}
+		if !l.shadowing && l.Has(asModule, key.Name) {
+			return fmt.Errorf("import of `%s::%s` defined twice", asModule, key.Name)
+		}
+		names = append(names, key.Name)
+		defines = append(defines, define)
+	}
+	// Defining aliases can only fail due to shadowing, which was checked
+	// above, so either all of the items are defined or none are.
+	for i, name := range names {
+		if err := defines[i](asModule, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns a function defining the item defined as `key` again under another
+// name, if it can be aliased.
+func (l *Linker) aliasable(key ImportKey) (func(module, name string) error, error) {
+	if f, ok := l.funcs[key]; ok {
+		return func(module, name string) error {
+			return l.defineLinkerFunc(module, name, f)
+		}, nil
+	}
+	if t, ok := l.templates[key]; ok {
+		return func(module, name string) error {
+			return l.defineTemplate(module, name, t)
+		}, nil
+	}
+	if !l.Has(key.Module, key.Name) {
+		return nil, fmt.Errorf("no item named `%s::%s` is defined in the linker", key.Module, key.Name)
+	}
+	if key.Module == "wasi_snapshot_preview1" {
+		return nil, fmt.Errorf("`%s::%s` can't be aliased as WASI functions are only defined under `wasi_snapshot_preview1`", key.Module, key.Name)
+	}
+	return nil, fmt.Errorf("`%s::%s` can't be aliased as it's defined within a store", key.Module, key.Name)
+}
+
+func (l *Linker) defineLinkerFunc(module, name string, f linkerFunc) error {
+	if f.wrap != nil {
+		return l.FuncWrap(module, name, f.wrap)
+	}
+	return l.FuncNew(module, name, f.ty, f.new)
+}
+
 // DefineInstance defines all exports of an instance provided under the module name provided.
 //
 // Returns an error if shadowing is disabled and names are already defined.
 func (l *Linker) DefineInstance(store Storelike, module string, instance *Instance) error {
+	names := instanceExportNames(store, instance)
+	if err := l.checkTemplates(module, names...); err != nil {
+		return err
+	}
 	err := C.wasmtime_linker_define_instance(
 		l.ptr(),
 		store.Context(),
@@ -165,6 +333,9 @@ func (l *Linker) DefineInstance(store Storelike, module string, instance *Instan
 	runtime.KeepAlive(module)
 	runtime.KeepAlive(store)
 	if err == nil {
+		for _, name := range names {
+			l.record(module, name)
+		}
 		return nil
 	}
 
@@ -179,22 +350,74 @@ func (l *Linker) DefineInstance(store Storelike, module string, instance *Instan
 // information see the Rust documentation --
 // https://docs.wasmtime.dev/api/wasmtime/struct.Linker.html#method.module.
 func (l *Linker) DefineModule(store Storelike, name string, module *Module) error {
-	err := C.wasmtime_linker_module(
-		l.ptr(),
-		store.Context(),
-		C._GoStringPtr(name),
-		C._GoStringLen(name),
-		module.ptr(),
-	)
-	runtime.KeepAlive(l)
-	runtime.KeepAlive(name)
-	runtime.KeepAlive(module)
-	runtime.KeepAlive(store)
-	if err == nil {
-		return nil
+	// Commands only have their functions defined, while reactors have all of
+	// their exports defined.
+	exports := module.Exports()
+	command := false
+	for _, export := range exports {
+		if export.Name() == "_start" {
+			command = true
+		}
+	}
+	var names []string
+	for _, export := range exports {
+		if !command || export.Type().FuncType() != nil {
+			names = append(names, export.Name())
+		}
+	}
+	if err := l.checkTemplates(name, names...); err != nil {
+		return err
 	}
 
-	return mkError(err)
+	getDataInStore(store).useModule(module)
+	return l.withStoreLinker(store, func(ptr *C.wasmtime_linker_t) error {
+		err := C.wasmtime_linker_module(
+			ptr,
+			store.Context(),
+			C._GoStringPtr(name),
+			C._GoStringLen(name),
+			module.ptr(),
+		)
+		runtime.KeepAlive(l)
+		runtime.KeepAlive(name)
+		runtime.KeepAlive(module)
+		runtime.KeepAlive(store)
+		if err != nil {
+			return mkError(err)
+		}
+		if ptr == l.ptr() {
+			for _, export := range names {
+				l.record(name, export)
+			}
+			return nil
+		}
+
+		// The module's imports were resolved with this store's clone of the
+		// linker, including its templates, so its exports were defined in
+		// the clone and are copied back into this linker.
+		for _, export := range names {
+			var item C.wasmtime_extern_t
+			ok := C.wasmtime_linker_get(
+				ptr,
+				store.Context(),
+				C._GoStringPtr(name),
+				C._GoStringLen(name),
+				C._GoStringPtr(export),
+				C._GoStringLen(export),
+				&item,
+			)
+			runtime.KeepAlive(name)
+			runtime.KeepAlive(export)
+			runtime.KeepAlive(store)
+			if !ok {
+				return fmt.Errorf("export `%s` of module `%s` wasn't defined", export, name)
+			}
+			if err := l.Define(store, name, export, mkExtern(&item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // DefineUnknownImportsAsTraps defines all otherwise-missing imports of the
@@ -209,6 +432,7 @@ func (l *Linker) DefineUnknownImportsAsTraps(module *Module) error {
 	runtime.KeepAlive(l)
 	runtime.KeepAlive(module)
 	if err == nil {
+		l.recordImports(module)
 		return nil
 	}
 
@@ -229,6 +453,7 @@ func (l *Linker) DefineUnknownImportsAsDefaultValues(store Storelike, module *Mo
 	runtime.KeepAlive(store)
 	runtime.KeepAlive(module)
 	if err == nil {
+		l.recordImports(module)
 		return nil
 	}
 
@@ -240,9 +465,13 @@ func (l *Linker) DefineUnknownImportsAsDefaultValues(store Storelike, module *Mo
 // Returns an error if the instance's imports couldn't be satisfied, had the
 // wrong types, or if a trap happened executing the start function.
 func (l *Linker) Instantiate(store Storelike, module *Module) (*Instance, error) {
+	data := getDataInStore(store)
+	data.useModule(module)
 	var ret C.wasmtime_instance_t
-	err := enterWasm(store, func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
-		return C.wasmtime_linker_instantiate(l.ptr(), store.Context(), module.ptr(), &ret, trap)
+	err := l.withStoreLinker(store, func(ptr *C.wasmtime_linker_t) error {
+		return enterWasm(store, nil, func(trap **C.wasm_trap_t) *C.wasmtime_error_t {
+			return C.wasmtime_linker_instantiate(ptr, store.Context(), module.ptr(), &ret, trap)
+		})
 	})
 	runtime.KeepAlive(l)
 	runtime.KeepAlive(module)
@@ -250,7 +479,11 @@ func (l *Linker) Instantiate(store Storelike, module *Module) (*Instance, error)
 	if err != nil {
 		return nil, err
 	}
-	return mkInstance(ret), nil
+	instance := mkInstance(ret)
+	if data.metrics != nil {
+		data.metrics.recordInstance(store, instance)
+	}
+	return instance, nil
 }
 
 // GetDefault acquires the "default export" of the named module in this linker.
@@ -284,6 +517,14 @@ func (l *Linker) GetDefault(store Storelike, name string) (*Func, error) {
 // If the item isn't defined then nil is returned, otherwise the item is
 // returned.
 func (l *Linker) Get(store Storelike, module, name string) *Extern {
+	if t, ok := l.templates[ImportKey{module, name}]; ok {
+		item, err := t.get(store)
+		if err != nil {
+			return nil
+		}
+		extern := item.AsExtern()
+		return mkExtern(&extern)
+	}
 	var ret C.wasmtime_extern_t
 	ok := C.wasmtime_linker_get(
 		l.ptr(),
@@ -304,3 +545,79 @@ func (l *Linker) Get(store Storelike, module, name string) *Extern {
 	return nil
 
 }
+
+// Has returns whether an item is defined as `module`/`name` within this linker.
+func (l *Linker) Has(module, name string) bool {
+	_, ok := l.defined[ImportKey{module, name}]
+	return ok
+}
+
+// UnresolvedImports returns the imports of `module` which aren't defined
+// within this linker, in the order they're imported.
+//
+// Note that only the names of imports are resolved, so instantiating `module`
+// can still fail if the types of the items defined don't match those of its
+// imports.
+func (l *Linker) UnresolvedImports(module *Module) []*ImportType {
+	var ret []*ImportType
+	for _, ty := range module.Imports() {
+		if !l.Has(ty.Module(), importName(ty)) {
+			ret = append(ret, ty)
+		}
+	}
+	return ret
+}
+
+// Records that `module`/`name` has been defined, replacing any function or
+// template previously defined under that name.
+func (l *Linker) record(module, name string) {
+	key := ImportKey{module, name}
+	delete(l.funcs, key)
+	delete(l.templates, key)
+	l.generation++
+	if _, ok := l.defined[key]; !ok {
+		l.defined[key] = struct{}{}
+		l.keys = append(l.keys, key)
+	}
+}
+
+// Records that all of the otherwise-missing imports of `module` have been
+// defined.
+func (l *Linker) recordImports(module *Module) {
+	for _, ty := range module.Imports() {
+		if !l.Has(ty.Module(), importName(ty)) {
+			l.record(ty.Module(), importName(ty))
+		}
+	}
+}
+
+func importName(ty *ImportType) string {
+	if name := ty.Name(); name != nil {
+		return *name
+	}
+	return ""
+}
+
+// Returns the names of the exports of `instance`.
+func instanceExportNames(store Storelike, instance *Instance) []string {
+	var ret []string
+	var name *C.char
+	var nameLen C.size_t
+	for i := 0; ; i++ {
+		var item C.wasmtime_extern_t
+		ok := C.wasmtime_instance_export_nth(
+			store.Context(),
+			&instance.val,
+			C.size_t(i),
+			&name,
+			&nameLen,
+			&item,
+		)
+		if !ok {
+			break
+		}
+		ret = append(ret, C.GoStringN(name, C.int(nameLen)))
+	}
+	runtime.KeepAlive(store)
+	return ret
+}
@@ -2,18 +2,85 @@ package wasmtime
 
 // #include <wasmtime.h>
 import "C"
-import "runtime"
+import (
+	"errors"
+	"runtime"
+)
 
 // DefineWasi links a WASI module into this linker, ensuring that all exported functions
 // are available for linking.
 //
+// The functions defined are recorded for `Has` and `Definitions` on a
+// best-effort basis, from a list of WASI functions kept by this package as
+// the C API can't list them, so any that Wasmtime defines beyond that list
+// aren't reported by them.
+//
 // Returns an error if shadowing is disabled and names are already defined.
 func (l *Linker) DefineWasi() error {
+	if err := l.checkTemplates("wasi_snapshot_preview1", wasiPreview1Funcs...); err != nil {
+		return err
+	}
 	err := C.wasmtime_linker_define_wasi(l.ptr())
 	runtime.KeepAlive(l)
 	if err == nil {
+		for _, name := range wasiPreview1Funcs {
+			l.record("wasi_snapshot_preview1", name)
+		}
 		return nil
 	}
 
 	return mkError(err)
 }
+
+// The functions `DefineWasi` is known to define.
+var wasiPreview1Funcs = []string{
+	"args_get", "args_sizes_get", "environ_get", "environ_sizes_get",
+	"clock_res_get", "clock_time_get",
+	"fd_advise", "fd_allocate", "fd_close", "fd_datasync", "fd_fdstat_get",
+	"fd_fdstat_set_flags", "fd_fdstat_set_rights", "fd_filestat_get",
+	"fd_filestat_set_size", "fd_filestat_set_times", "fd_pread",
+	"fd_prestat_get", "fd_prestat_dir_name", "fd_pwrite", "fd_read",
+	"fd_readdir", "fd_renumber", "fd_seek", "fd_sync", "fd_tell", "fd_write",
+	"path_create_directory", "path_filestat_get", "path_filestat_set_times",
+	"path_link", "path_open", "path_readlink", "path_remove_directory",
+	"path_rename", "path_symlink", "path_unlink_file",
+	"poll_oneoff", "proc_exit", "proc_raise", "sched_yield", "random_get",
+	"sock_accept", "sock_recv", "sock_send", "sock_shutdown",
+}
+
+// RunWasiCommand runs `module` as a WASI command, returning its exit code.
+//
+// The module is instantiated with `linker`, which isn't modified, and its
+// default export is then invoked: `_start` if it's exported, or otherwise the
+// export named with the empty string, if any. The `linker` is expected to
+// already contain WASI definitions, for example through `Linker.DefineWasi`,
+// and `store` to have been configured with `Store.SetWasi`.
+//
+// A command which returns normally, or which exits with status 0, is
+// considered successful and returns an exit code of 0 and a nil error. A
+// command exiting with any other status returns that status along with the
+// `*ExitError` describing it. For any other failure, such as a trap, an exit
+// code of -1 is returned along with the error.
+func RunWasiCommand(store Storelike, linker *Linker, module *Module) (int, error) {
+	instance, err := linker.Instantiate(store, module)
+	if err == nil {
+		start := instance.GetFunc(store, "_start")
+		if start == nil {
+			start = instance.GetFunc(store, "")
+		}
+		if start != nil {
+			_, err = start.Call(store)
+		}
+	}
+	if err == nil {
+		return 0, nil
+	}
+	var exit *ExitError
+	if errors.As(err, &exit) {
+		if exit.ExitCode() == 0 {
+			return 0, nil
+		}
+		return int(exit.ExitCode()), err
+	}
+	return -1, err
+}
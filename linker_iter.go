@@ -0,0 +1,29 @@
+//go:build go1.23
+// +build go1.23
+
+package wasmtime
+
+import "iter"
+
+// Definitions returns an iterator over the items defined within this linker,
+// in the order they were first defined, along with the module and name they're
+// defined as.
+//
+// Store-independent definitions, such as those of `FuncWrap`, are returned as
+// items within `store`.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Linker.html#method.iter
+func (l *Linker) Definitions(store Storelike) iter.Seq2[ImportKey, *Extern] {
+	return func(yield func(ImportKey, *Extern) bool) {
+		for _, key := range l.keys {
+			item := l.Get(store, key.Module, key.Name)
+			if item == nil {
+				continue
+			}
+			if !yield(key, item) {
+				return
+			}
+		}
+	}
+}
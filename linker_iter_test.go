@@ -0,0 +1,45 @@
+//go:build go1.23
+// +build go1.23
+
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkerDefinitions(t *testing.T) {
+	store := NewStore(NewEngine())
+	linker := NewLinker(store.Engine)
+	linker.AllowShadowing(true)
+	require.NoError(t, linker.FuncWrap("env", "f", func() {}))
+	ty, err := NewMemoryType(1, false, 0, false)
+	require.NoError(t, err)
+	mem, err := NewMemory(store, ty)
+	require.NoError(t, err)
+	require.NoError(t, linker.Define(store, "env", "mem", mem))
+	// redefinitions don't duplicate names
+	require.NoError(t, linker.FuncWrap("env", "f", func(int32) {}))
+
+	var keys []ImportKey
+	for key, item := range linker.Definitions(store) {
+		keys = append(keys, key)
+		switch key.Name {
+		case "f":
+			require.NotNil(t, item.Func())
+			require.Len(t, item.Func().Type(store).Params(), 1)
+		case "mem":
+			require.NotNil(t, item.Memory())
+		}
+	}
+	require.Equal(t, []ImportKey{{"env", "f"}, {"env", "mem"}}, keys)
+
+	// iteration can stop early
+	count := 0
+	for range linker.Definitions(store) {
+		count++
+		break
+	}
+	require.Equal(t, 1, count)
+}
@@ -0,0 +1,184 @@
+package wasmtime
+
+// #include <wasmtime.h>
+import "C"
+import (
+	"fmt"
+	"runtime"
+)
+
+// A definition within a `Linker` of an item which is created afresh within
+// each store the linker is used with.
+type linkerTemplate struct {
+	create func(Storelike) (AsExtern, error)
+}
+
+// DefineGlobalTemplate defines a global of type `ty` in this linker which is
+// created separately within each store used to instantiate modules with this
+// linker, and initialized to the value returned by `init`.
+//
+// Like `FuncNew` and `FuncWrap` this doesn't require a `Storelike`, allowing
+// a single linker to be shared between many stores. The global is created the
+// first time it's needed by `Instantiate` or `Get` for a store, and the same
+// global is then used for every later instantiation within that store.
+//
+// Note that templates are only made available to `Instantiate` and `Get`, and
+// not to modules instantiated through `DefineModule`.
+//
+// Runs `f` with the C linker returned by `storeLinker` for `store`, which is
+// kept alive until `f` returns even if this linker is changed meanwhile.
+func (l *Linker) withStoreLinker(store Storelike, f func(*C.wasmtime_linker_t) error) error {
+	ptr, err := l.storeLinker(store)
+	if err != nil {
+		return err
+	}
+	data := getDataInStore(store)
+	data.linkersInUse++
+	defer func() {
+		data.linkersInUse--
+		if data.linkersInUse == 0 {
+			for _, stale := range data.staleLinkers {
+				C.wasmtime_linker_delete(stale)
+			}
+			data.staleLinkers = nil
+		}
+	}()
+	return f(ptr)
+}
+
+// Returns an error if shadowing is disabled and the name is already defined.
+func (l *Linker) DefineGlobalTemplate(module, name string, ty *GlobalType, init func(Storelike) Val) error {
+	return l.defineTemplate(module, name, &linkerTemplate{func(store Storelike) (AsExtern, error) {
+		return NewGlobal(store, ty, init(store))
+	}})
+}
+
+// DefineMemoryTemplate defines a memory of type `ty` in this linker which is
+// created separately within each store used to instantiate modules with this
+// linker.
+//
+// The memory is created the first time it's needed by `Instantiate` or `Get`
+// for a store, as with `DefineGlobalTemplate`.
+//
+// Returns an error if shadowing is disabled and the name is already defined.
+func (l *Linker) DefineMemoryTemplate(module, name string, ty *MemoryType) error {
+	return l.defineTemplate(module, name, &linkerTemplate{func(store Storelike) (AsExtern, error) {
+		return NewMemory(store, ty)
+	}})
+}
+
+func (l *Linker) defineTemplate(module, name string, t *linkerTemplate) error {
+	if !l.shadowing && l.Has(module, name) {
+		return fmt.Errorf("import of `%s::%s` defined twice", module, name)
+	}
+	l.record(module, name)
+	l.templates[ImportKey{module, name}] = t
+	return nil
+}
+
+// Returns the item created for this template within `store`, creating it if
+// this is the first time it's been used within `store`.
+func (t *linkerTemplate) get(store Storelike) (AsExtern, error) {
+	data := getDataInStore(store)
+	if item, ok := data.templates[t]; ok {
+		return item, nil
+	}
+	item, err := t.create(store)
+	if err != nil {
+		return nil, err
+	}
+	if data.templates == nil {
+		data.templates = make(map[*linkerTemplate]AsExtern)
+	}
+	data.templates[t] = item
+	return item, nil
+}
+
+// A clone of a linker with the items of its templates in one store defined,
+// cached within that store's `storeData`.
+type storeLinker struct {
+	// the `Linker.generation` the clone was made at
+	generation uint64
+	ptr        *C.wasmtime_linker_t
+}
+
+// Returns the C linker to instantiate modules within `store` with.
+//
+// If this linker has no templates that's the linker itself, and otherwise it's
+// a clone of the linker with the items of its templates in `store` defined.
+// The clone is cached within `store`, and only made again once this linker
+// has been changed.
+func (l *Linker) storeLinker(store Storelike) (*C.wasmtime_linker_t, error) {
+	ptr := l.ptr()
+	if len(l.templates) == 0 {
+		return ptr, nil
+	}
+	data := getDataInStore(store)
+	cached, ok := data.linkers[l.id]
+	if ok && cached.generation == l.generation {
+		return cached.ptr, nil
+	}
+	if ok {
+		// The stale clone may still be in use by an instantiation further up
+		// the stack, in which case it's deleted once that's finished.
+		if data.linkersInUse == 0 {
+			C.wasmtime_linker_delete(cached.ptr)
+		} else {
+			data.staleLinkers = append(data.staleLinkers, cached.ptr)
+		}
+		delete(data.linkers, l.id)
+	}
+
+	// Templates have already been checked against shadowing when they were
+	// defined, and take precedence over the items defined before them.
+	clone := C.wasmtime_linker_clone(ptr)
+	C.wasmtime_linker_allow_shadowing(clone, true)
+	runtime.KeepAlive(l)
+	for _, key := range l.keys {
+		t, ok := l.templates[key]
+		if !ok {
+			continue
+		}
+		item, err := t.get(store)
+		if err != nil {
+			C.wasmtime_linker_delete(clone)
+			return nil, err
+		}
+		extern := item.AsExtern()
+		cerr := C.wasmtime_linker_define(
+			clone,
+			store.Context(),
+			C._GoStringPtr(key.Module),
+			C._GoStringLen(key.Module),
+			C._GoStringPtr(key.Name),
+			C._GoStringLen(key.Name),
+			&extern,
+		)
+		runtime.KeepAlive(key)
+		runtime.KeepAlive(item)
+		runtime.KeepAlive(store)
+		if cerr != nil {
+			C.wasmtime_linker_delete(clone)
+			return nil, mkError(cerr)
+		}
+	}
+	if data.linkers == nil {
+		data.linkers = make(map[uint64]storeLinker)
+	}
+	data.linkers[l.id] = storeLinker{generation: l.generation, ptr: clone}
+	return clone, nil
+}
+
+// Returns an error if shadowing is disabled and any of `names` within
+// `module` is defined by a template, which the C linker can't detect.
+func (l *Linker) checkTemplates(module string, names ...string) error {
+	if l.shadowing {
+		return nil
+	}
+	for _, name := range names {
+		if _, ok := l.templates[ImportKey{module, name}]; ok {
+			return fmt.Errorf("import of `%s::%s` defined twice", module, name)
+		}
+	}
+	return nil
+}
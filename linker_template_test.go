@@ -0,0 +1,149 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkerTemplates(t *testing.T) {
+	engine := NewEngine()
+	linker := NewLinker(engine)
+	globalTy := NewGlobalType(NewValType(KindI32), true)
+	memTy, err := NewMemoryType(1, false, 0, false)
+	require.NoError(t, err)
+	inits := 0
+	require.NoError(t, linker.DefineGlobalTemplate("env", "counter", globalTy, func(Storelike) Val {
+		inits++
+		return ValI32(10)
+	}))
+	require.NoError(t, linker.DefineMemoryTemplate("env", "mem", memTy))
+	require.True(t, linker.Has("env", "counter"))
+	require.True(t, linker.Has("env", "mem"))
+
+	wasm, err := Wat2Wasm(`(module
+	  (import "env" "counter" (global $g (mut i32)))
+	  (import "env" "mem" (memory 1))
+	  (func (export "bump") (result i32)
+	    (global.set $g (i32.add (global.get $g) (i32.const 1)))
+	    (i32.store (i32.const 0) (global.get $g))
+	    (global.get $g))
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	bump := func(store *Store) int32 {
+		instance, err := linker.Instantiate(store, module)
+		require.NoError(t, err)
+		ret, err := instance.GetFunc(store, "bump").Call(store)
+		require.NoError(t, err)
+		return ret.(int32)
+	}
+
+	// Instances within a store share the store's items, while each store gets
+	// items of its own.
+	store1 := NewStore(engine)
+	store2 := NewStore(engine)
+	require.Equal(t, int32(11), bump(store1))
+	require.Equal(t, int32(12), bump(store1))
+	require.Equal(t, int32(11), bump(store2))
+	require.Equal(t, 2, inits)
+
+	counter := linker.Get(store1, "env", "counter").Global()
+	require.NotNil(t, counter)
+	require.Equal(t, int32(12), counter.Get(store1).I32())
+	mem := linker.Get(store2, "env", "mem").Memory()
+	require.NotNil(t, mem)
+	require.Equal(t, byte(11), mem.UnsafeData(store2)[0])
+
+	// Templates obey shadowing like any other definition.
+	require.Error(t, linker.DefineMemoryTemplate("env", "mem", memTy))
+	linker.AllowShadowing(true)
+	require.NoError(t, linker.DefineGlobalTemplate("env", "counter", globalTy, func(Storelike) Val {
+		return ValI32(100)
+	}))
+	require.Equal(t, int32(101), bump(NewStore(engine)))
+}
+
+func TestLinkerTemplateTypeMismatch(t *testing.T) {
+	engine := NewEngine()
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineGlobalTemplate("env", "g", NewGlobalType(NewValType(KindI32), false), func(Storelike) Val {
+		return ValI64(1)
+	}))
+
+	wasm, err := Wat2Wasm(`(module (import "env" "g" (global i32)))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	store := NewStore(engine)
+	_, err = linker.Instantiate(store, module)
+	require.Error(t, err)
+	require.Nil(t, linker.Get(store, "env", "g"))
+}
+
+func TestLinkerTemplateShadowing(t *testing.T) {
+	engine := NewEngine()
+	store := NewStore(engine)
+	linker := NewLinker(engine)
+	memTy, err := NewMemoryType(1, false, 0, false)
+	require.NoError(t, err)
+	require.NoError(t, linker.DefineMemoryTemplate("env", "mem", memTy))
+
+	mem, err := NewMemory(store, memTy)
+	require.NoError(t, err)
+	require.Error(t, linker.Define(store, "env", "mem", mem))
+	require.Error(t, linker.FuncWrap("env", "mem", func() {}))
+	require.NotNil(t, linker.Get(store, "env", "mem").Memory())
+
+	// Definitions made after a store has instantiated with the linker are
+	// visible to its later instantiations.
+	wasm, err := Wat2Wasm(`(module
+	  (import "env" "mem" (memory 1))
+	  (import "env" "f" (func))
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	_, err = linker.Instantiate(store, module)
+	require.Error(t, err)
+	require.NoError(t, linker.FuncWrap("env", "f", func() {}))
+	_, err = linker.Instantiate(store, module)
+	require.NoError(t, err)
+}
+
+func TestLinkerTemplateDefineModule(t *testing.T) {
+	engine := NewEngine()
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineGlobalTemplate("env", "base", NewGlobalType(NewValType(KindI32), false), func(Storelike) Val {
+		return ValI32(40)
+	}))
+
+	wasm, err := Wat2Wasm(`(module
+	  (import "env" "base" (global $base i32))
+	  (func (export "get") (result i32)
+	    (i32.add (global.get $base) (i32.const 2)))
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	require.Empty(t, linker.UnresolvedImports(module))
+
+	store := NewStore(engine)
+	require.NoError(t, linker.DefineModule(store, "reactor", module))
+	get := linker.Get(store, "reactor", "get").Func()
+	require.NotNil(t, get)
+	ret, err := get.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(42), ret)
+
+	// Changing the linker replaces the store's clone of it, which is still
+	// usable afterwards.
+	require.NoError(t, linker.FuncWrap("env", "f", func() {}))
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	ret, err = instance.GetFunc(store, "get").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(42), ret)
+}
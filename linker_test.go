@@ -321,3 +321,124 @@ func TestPanicInHostFunctionDuringCallWithCaller(t *testing.T) {
 		foo.Call(store)
 	})
 }
+
+func TestLinkerHas(t *testing.T) {
+	store := NewStore(NewEngine())
+	linker := NewLinker(store.Engine)
+	require.False(t, linker.Has("env", "f"))
+	require.NoError(t, linker.FuncWrap("env", "f", func() {}))
+	require.NoError(t, linker.DefineFunc(store, "env", "g", func() {}))
+	require.True(t, linker.Has("env", "f"))
+	require.True(t, linker.Has("env", "g"))
+	require.False(t, linker.Has("env", "h"))
+	require.False(t, linker.Has("other", "f"))
+
+	wasm, err := Wat2Wasm(`(module
+	  (func (export "a"))
+	  (memory (export "mem") 1)
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+	require.NoError(t, linker.DefineInstance(store, "inst", instance))
+	require.True(t, linker.Has("inst", "a"))
+	require.True(t, linker.Has("inst", "mem"))
+
+	require.NoError(t, linker.DefineWasi())
+	require.True(t, linker.Has("wasi_snapshot_preview1", "fd_write"))
+}
+
+func TestLinkerUnresolvedImports(t *testing.T) {
+	store := NewStore(NewEngine())
+	linker := NewLinker(store.Engine)
+	require.NoError(t, linker.FuncWrap("env", "f", func() {}))
+
+	wasm, err := Wat2Wasm(`(module
+	  (import "env" "f" (func))
+	  (import "env" "g" (func))
+	  (import "other" "mem" (memory 1))
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+
+	unresolved := linker.UnresolvedImports(module)
+	require.Len(t, unresolved, 2)
+	require.Equal(t, "env", unresolved[0].Module())
+	require.Equal(t, "g", *unresolved[0].Name())
+	require.Equal(t, "other", unresolved[1].Module())
+	require.Equal(t, "mem", *unresolved[1].Name())
+
+	require.NoError(t, linker.DefineUnknownImportsAsTraps(module))
+	require.Empty(t, linker.UnresolvedImports(module))
+}
+
+func TestLinkerAlias(t *testing.T) {
+	store := NewStore(NewEngine())
+	linker := NewLinker(store.Engine)
+	require.NoError(t, linker.FuncWrap("env", "add", func(a, b int32) int32 { return a + b }))
+	require.NoError(t, linker.FuncNew("env", "one", NewFuncType(nil, []*ValType{NewValType(KindI32)}), func(*Caller, []Val) ([]Val, *Trap) {
+		return []Val{ValI32(1)}, nil
+	}))
+	require.NoError(t, linker.Alias("env", "add", "env", "plus"))
+	require.NoError(t, linker.AliasModule("env", "host_v2"))
+	require.True(t, linker.Has("host_v2", "add"))
+	require.True(t, linker.Has("host_v2", "plus"))
+
+	wasm, err := Wat2Wasm(`(module
+	  (import "host_v2" "plus" (func $plus (param i32 i32) (result i32)))
+	  (import "host_v2" "one" (func $one (result i32)))
+	  (func (export "run") (result i32)
+	    (call $plus (call $one) (i32.const 2)))
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	ret, err := instance.GetFunc(store, "run").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), ret)
+
+	// Names which are already defined can't be aliased onto without shadowing.
+	require.Error(t, linker.Alias("env", "add", "env", "one"))
+	require.Error(t, linker.Alias("env", "missing", "env", "other"))
+
+	// Store-bound items can't be aliased, and neither can their module.
+	require.NoError(t, linker.DefineFunc(store, "store", "f", func() {}))
+	require.NoError(t, linker.FuncWrap("store", "g", func() {}))
+	require.Error(t, linker.Alias("store", "f", "store", "h"))
+	require.Error(t, linker.AliasModule("store", "other"))
+	require.False(t, linker.Has("other", "g"))
+
+	// Nothing is aliased if any name in the module is already defined.
+	require.NoError(t, linker.FuncWrap("partial", "a", func() {}))
+	require.NoError(t, linker.FuncWrap("partial", "b", func() {}))
+	require.NoError(t, linker.FuncWrap("target", "b", func() {}))
+	require.Error(t, linker.AliasModule("partial", "target"))
+	require.False(t, linker.Has("target", "a"))
+
+	// Templates can be aliased, referring to the same item within a store.
+	require.NoError(t, linker.DefineGlobalTemplate("tmpl", "g", NewGlobalType(NewValType(KindI32), false), func(Storelike) Val {
+		return ValI32(1)
+	}))
+	require.NoError(t, linker.Alias("tmpl", "g", "tmpl", "h"))
+	require.NotNil(t, linker.Get(store, "tmpl", "h"))
+
+	// Shadowing a function with a store-bound item means it can no longer be
+	// aliased.
+	linker.AllowShadowing(true)
+	require.NoError(t, linker.DefineFunc(store, "env", "add", func() {}))
+	require.Error(t, linker.Alias("env", "add", "env", "sum"))
+}
+
+func TestLinkerAliasWasi(t *testing.T) {
+	linker := NewLinker(NewEngine())
+	require.NoError(t, linker.DefineWasi())
+	err := linker.AliasModule("wasi_snapshot_preview1", "wasi_unstable")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "WASI")
+	require.False(t, linker.Has("wasi_unstable", "fd_write"))
+}
@@ -24,6 +24,9 @@ func NewMemory(store Storelike, ty *MemoryType) (*Memory, error) {
 	if err != nil {
 		return nil, mkError(err)
 	}
+	if m := getDataInStore(store).metrics; m != nil {
+		m.memories[ret] = struct{}{}
+	}
 	return mkMemory(ret), nil
 }
 
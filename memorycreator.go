@@ -0,0 +1,198 @@
+package wasmtime
+
+// #include <stdlib.h>
+// #include "shims.h"
+import "C"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// Objects implemented in Go, such as a `MemoryCreator`, whose lifetime is
+// managed by Wasmtime. They're referred to from C by their index plus one, so
+// that the index is never null.
+var gHostObjectLock sync.Mutex
+var gHostObjectMap = make(map[int]interface{})
+var gHostObjectSlab slab
+
+func insertHostObject(obj interface{}) C.size_t {
+	gHostObjectLock.Lock()
+	defer gHostObjectLock.Unlock()
+	idx := gHostObjectSlab.allocate()
+	gHostObjectMap[idx] = obj
+	return C.size_t(idx + 1)
+}
+
+func getHostObject(env C.size_t) interface{} {
+	gHostObjectLock.Lock()
+	defer gHostObjectLock.Unlock()
+	return gHostObjectMap[int(env)-1]
+}
+
+//export goFinalizeHostObject
+func goFinalizeHostObject(env unsafe.Pointer) {
+	idx := int(uintptr(env)) - 1
+	gHostObjectLock.Lock()
+	obj := gHostObjectMap[idx]
+	delete(gHostObjectMap, idx)
+	gHostObjectSlab.deallocate(idx)
+	gHostObjectLock.Unlock()
+
+	if mem, ok := obj.(*hostLinearMemory); ok {
+		obj = mem.mem
+	}
+	if closer, ok := obj.(io.Closer); ok {
+		// There's nobody to report the error to at this point.
+		_ = hostCallback(closer.Close)
+	}
+}
+
+// Runs `f`, a callback into Go from Wasmtime with no store to propagate panics
+// through, turning a panic into an error as it can't unwind through Wasmtime.
+func hostCallback(f func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("go panicked: %v", p)
+		}
+	}()
+	return f()
+}
+
+// Converts `err` to an error returned to Wasmtime.
+func mkHostError(err error) *C.wasmtime_error_t {
+	msg := C.CString(err.Error())
+	defer C.free(unsafe.Pointer(msg))
+	return C.wasmtime_error_new(msg)
+}
+
+// MemoryCreator creates the linear memories of an `Engine`, allowing the host
+// to control how linear memories are allocated, see `Config.SetHostMemory`.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/trait.MemoryCreator.html
+type MemoryCreator interface {
+	// NewMemory creates a new linear memory of type `ty`.
+	//
+	// The memory must be at least `minimum` bytes in size, and may never
+	// grow beyond `maximum` bytes, which is the largest `uintptr` if the
+	// memory has no maximum. If `reservedSize` isn't 0 then the memory is
+	// expected to reserve that many bytes up front and never move when it's
+	// grown. Beyond the end of the memory's reservation `guardSize` bytes
+	// must be reserved and inaccessible, which Wasmtime relies on to elide
+	// bounds checks.
+	NewMemory(ty *MemoryType, minimum, maximum, reservedSize, guardSize uintptr) (LinearMemory, error)
+}
+
+// LinearMemory is a linear memory created by a `MemoryCreator`.
+//
+// The memory backing a `LinearMemory` must not be managed by the Go garbage
+// collector, as Wasmtime retains pointers to it. It can instead be allocated
+// with, for example, `mmap`. If a `LinearMemory` also implements `io.Closer`
+// then its `Close` method is called once Wasmtime no longer uses it, where its
+// memory can be released.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/trait.LinearMemory.html
+//
+// Wasmtime has no way to handle a failure of `Base`, `ByteSize` or
+// `ByteCapacity`, so if one of them panics the values they last returned are
+// used instead.
+type LinearMemory interface {
+	// Base returns a pointer to the start of this memory.
+	Base() unsafe.Pointer
+	// ByteSize returns the current size, in bytes, of this memory.
+	ByteSize() uintptr
+	// ByteCapacity returns the size, in bytes, this memory can grow to
+	// without moving, which is at least its `ByteSize`.
+	ByteCapacity() uintptr
+	// Grow grows this memory to `newSize` bytes, zeroing the new bytes. An
+	// error is returned if the memory can't be grown, in which case
+	// WebAssembly's `memory.grow` returns -1.
+	Grow(newSize uintptr) error
+}
+
+// SetHostMemory configures `creator` to create all of the linear memories of
+// engines created with this configuration, rather than Wasmtime allocating
+// them itself.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Config.html#method.with_host_memory
+func (cfg *Config) SetHostMemory(creator MemoryCreator) {
+	C.go_config_host_memory_creator_set(cfg.ptr(), insertHostObject(creator))
+	runtime.KeepAlive(cfg)
+}
+
+//export goMemoryCreatorNew
+func goMemoryCreatorNew(
+	env C.size_t,
+	ty *C.wasm_memorytype_t,
+	minimum, maximum, reservedSize, guardSize C.size_t,
+	ret *C.size_t,
+) *C.wasmtime_error_t {
+	creator := getHostObject(env).(MemoryCreator)
+	var mem LinearMemory
+	err := hostCallback(func() error {
+		var err error
+		mem, err = creator.NewMemory(
+			mkMemoryType(C.wasm_memorytype_copy(ty), nil),
+			uintptr(minimum),
+			uintptr(maximum),
+			uintptr(reservedSize),
+			uintptr(guardSize),
+		)
+		if err == nil && mem == nil {
+			err = errors.New("memory creator returned a nil memory")
+		}
+		return err
+	})
+	if err != nil {
+		return mkHostError(err)
+	}
+	*ret = insertHostObject(&hostLinearMemory{mem: mem})
+	return nil
+}
+
+// A `LinearMemory` registered with Wasmtime, along with the values its
+// accessors last returned.
+type hostLinearMemory struct {
+	mem            LinearMemory
+	base           unsafe.Pointer
+	size, capacity uintptr
+}
+
+//export goLinearMemoryGet
+func goLinearMemoryGet(env C.size_t, byteSize, byteCapacity *C.size_t) *C.uint8_t {
+	mem := getHostObject(env).(*hostLinearMemory)
+	// Wasmtime gives no way to fail here, so if the accessors panic the
+	// values they last returned are used instead.
+	var base unsafe.Pointer
+	var size, capacity uintptr
+	err := hostCallback(func() error {
+		size = mem.mem.ByteSize()
+		capacity = mem.mem.ByteCapacity()
+		base = mem.mem.Base()
+		return nil
+	})
+	if err == nil {
+		mem.base, mem.size, mem.capacity = base, size, capacity
+	}
+	*byteSize = C.size_t(mem.size)
+	*byteCapacity = C.size_t(mem.capacity)
+	return (*C.uint8_t)(mem.base)
+}
+
+//export goLinearMemoryGrow
+func goLinearMemoryGrow(env C.size_t, newSize C.size_t) *C.wasmtime_error_t {
+	mem := getHostObject(env).(*hostLinearMemory)
+	err := hostCallback(func() error {
+		return mem.mem.Grow(uintptr(newSize))
+	})
+	if err != nil {
+		return mkHostError(err)
+	}
+	return nil
+}
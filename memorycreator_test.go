@@ -0,0 +1,133 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package wasmtime
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A memory creator allocating memories with mmap.
+type mmapCreator struct {
+	memories []*mmapMemory
+}
+
+type mmapMemory struct {
+	region   []byte
+	size     uintptr
+	capacity uintptr
+	closed   bool
+}
+
+func (c *mmapCreator) NewMemory(ty *MemoryType, minimum, maximum, reservedSize, guardSize uintptr) (LinearMemory, error) {
+	capacity := reservedSize
+	if capacity == 0 {
+		capacity = 4 << 20
+		if maximum < capacity {
+			capacity = maximum
+		}
+	}
+	if minimum > capacity {
+		return nil, errors.New("memory too large")
+	}
+	region, err := syscall.Mmap(-1, 0, int(capacity+guardSize), syscall.PROT_NONE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+	mem := &mmapMemory{region: region, capacity: capacity}
+	if err := mem.Grow(minimum); err != nil {
+		return nil, err
+	}
+	c.memories = append(c.memories, mem)
+	return mem, nil
+}
+
+func (m *mmapMemory) Base() unsafe.Pointer {
+	return unsafe.Pointer(&m.region[0])
+}
+
+func (m *mmapMemory) ByteSize() uintptr {
+	return m.size
+}
+
+func (m *mmapMemory) ByteCapacity() uintptr {
+	return m.capacity
+}
+
+func (m *mmapMemory) Grow(newSize uintptr) error {
+	if newSize > m.capacity {
+		return errors.New("memory can't grow beyond its capacity")
+	}
+	if newSize > 0 {
+		if err := syscall.Mprotect(m.region[:newSize], syscall.PROT_READ|syscall.PROT_WRITE); err != nil {
+			return err
+		}
+	}
+	m.size = newSize
+	return nil
+}
+
+func (m *mmapMemory) Close() error {
+	m.closed = true
+	return syscall.Munmap(m.region)
+}
+
+func TestHostMemory(t *testing.T) {
+	creator := &mmapCreator{}
+	config := NewConfig()
+	config.SetHostMemory(creator)
+	engine := NewEngineWithConfig(config)
+	store := NewStore(engine)
+
+	wasm, err := Wat2Wasm(`(module
+	  (memory (export "memory") 1 2)
+	  (func (export "store") (param i32 i32) (i32.store (local.get 0) (local.get 1)))
+	  (func (export "grow") (param i32) (result i32) (memory.grow (local.get 0)))
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+	require.Len(t, creator.memories, 1)
+	mem := creator.memories[0]
+	require.Equal(t, uintptr(65536), mem.ByteSize())
+
+	_, err = instance.GetFunc(store, "store").Call(store, 8, 42)
+	require.NoError(t, err)
+	require.Equal(t, byte(42), mem.region[8])
+	require.Equal(t, instance.GetExport(store, "memory").Memory().Data(store), mem.Base())
+
+	ret, err := instance.GetFunc(store, "grow").Call(store, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), ret)
+	require.Equal(t, uintptr(2*65536), mem.ByteSize())
+	ret, err = instance.GetFunc(store, "grow").Call(store, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(-1), ret)
+
+	store.Close()
+	require.True(t, mem.closed)
+}
+
+type failingCreator struct{}
+
+func (failingCreator) NewMemory(ty *MemoryType, minimum, maximum, reservedSize, guardSize uintptr) (LinearMemory, error) {
+	return nil, errors.New("out of host memory")
+}
+
+func TestHostMemoryError(t *testing.T) {
+	config := NewConfig()
+	config.SetHostMemory(failingCreator{})
+	store := NewStore(NewEngineWithConfig(config))
+	ty, err := NewMemoryType(1, false, 0, false)
+	require.NoError(t, err)
+	_, err = NewMemory(store, ty)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of host memory")
+}
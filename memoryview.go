@@ -0,0 +1,139 @@
+package wasmtime
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+// MemoryView provides bounds-checked access to the contents of a `Memory`.
+//
+// WebAssembly memory is little-endian, and all integers are read and written
+// as such. Offsets are 64-bit to support 64-bit memories. Accesses which are
+// out of bounds return an error matching `ErrMemoryOutOfBounds` with
+// `errors.Is` rather than panicking.
+//
+// Unlike the slice returned by `Memory.UnsafeData` a view remains valid after
+// the memory is grown, since the memory's current data is looked up on every
+// access. Values returned from a view are copied out of the memory.
+type MemoryView struct {
+	mem   *Memory
+	store Storelike
+}
+
+// View returns a `MemoryView` of this memory within `store`, which can be a
+// `*Store` or, from within a host function, a `*Caller`.
+func (mem *Memory) View(store Storelike) *MemoryView {
+	return &MemoryView{mem: mem, store: store}
+}
+
+// Len returns the current size, in bytes, of the viewed memory.
+func (v *MemoryView) Len() uint64 {
+	return uint64(v.mem.DataSize(v.store))
+}
+
+// Returns the `length` bytes of memory at `offset`, or an error if they're out
+// of bounds. The returned slice is only valid until the memory is next grown.
+func (v *MemoryView) slice(offset, length uint64) ([]byte, error) {
+	size := v.Len()
+	if length > size || offset > size-length {
+		return nil, memoryOutOfBounds(offset, length, size)
+	}
+	if length == 0 {
+		return []byte{}, nil
+	}
+	base := v.mem.Data(v.store)
+	return unsafe.Slice((*byte)(unsafe.Add(base, uintptr(offset))), int(length)), nil
+}
+
+// ReadUint32 reads a little-endian `uint32` at `offset`.
+func (v *MemoryView) ReadUint32(offset uint64) (uint32, error) {
+	b, err := v.slice(offset, 4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// ReadUint64 reads a little-endian `uint64` at `offset`.
+func (v *MemoryView) ReadUint64(offset uint64) (uint64, error) {
+	b, err := v.slice(offset, 8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// WriteUint32 writes `val` as a little-endian `uint32` at `offset`.
+func (v *MemoryView) WriteUint32(offset uint64, val uint32) error {
+	b, err := v.slice(offset, 4)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(b, val)
+	return nil
+}
+
+// WriteUint64 writes `val` as a little-endian `uint64` at `offset`.
+func (v *MemoryView) WriteUint64(offset uint64, val uint64) error {
+	b, err := v.slice(offset, 8)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(b, val)
+	return nil
+}
+
+// ReadBytes returns a copy of the `length` bytes at `offset`.
+func (v *MemoryView) ReadBytes(offset, length uint64) ([]byte, error) {
+	b, err := v.slice(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), b...), nil
+}
+
+// WriteBytes copies `data` into memory at `offset`.
+func (v *MemoryView) WriteBytes(offset uint64, data []byte) error {
+	b, err := v.slice(offset, uint64(len(data)))
+	if err != nil {
+		return err
+	}
+	copy(b, data)
+	return nil
+}
+
+// ReadString returns the string of `length` bytes at `ptr`, as is commonly
+// passed from WebAssembly as a pointer and length pair.
+func (v *MemoryView) ReadString(ptr, length uint64) (string, error) {
+	b, err := v.slice(ptr, length)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ReadCString returns the NUL-terminated string at `ptr`, not including the
+// terminator. An error is returned if memory ends before a NUL byte is found.
+func (v *MemoryView) ReadCString(ptr uint64) (string, error) {
+	size := v.Len()
+	if ptr >= size {
+		return "", memoryOutOfBounds(ptr, 1, size)
+	}
+	rest, err := v.slice(ptr, size-ptr)
+	if err != nil {
+		return "", err
+	}
+	end := bytes.IndexByte(rest, 0)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string at offset %d in memory of size %d: %w",
+			ptr, size, ErrMemoryOutOfBounds)
+	}
+	return string(rest[:end]), nil
+}
+
+func memoryOutOfBounds(offset, length, size uint64) error {
+	return fmt.Errorf("memory access of %d bytes at offset %d is out of bounds of memory of size %d: %w",
+		length, offset, size, ErrMemoryOutOfBounds)
+}
@@ -0,0 +1,108 @@
+package wasmtime
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryView(t *testing.T) {
+	store := NewStore(NewEngine())
+	ty, err := NewMemoryType(1, false, 0, false)
+	require.NoError(t, err)
+	mem, err := NewMemory(store, ty)
+	require.NoError(t, err)
+	view := mem.View(store)
+	require.Equal(t, uint64(65536), view.Len())
+
+	require.NoError(t, view.WriteUint32(8, 0x01020304))
+	val32, err := view.ReadUint32(8)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0x01020304), val32)
+	b, err := view.ReadBytes(8, 4)
+	require.NoError(t, err)
+	require.Equal(t, []byte{4, 3, 2, 1}, b)
+
+	require.NoError(t, view.WriteUint64(65528, 0x0102030405060708))
+	val64, err := view.ReadUint64(65528)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x0102030405060708), val64)
+
+	require.NoError(t, view.WriteBytes(100, []byte("hello\x00world")))
+	s, err := view.ReadString(100, 5)
+	require.NoError(t, err)
+	require.Equal(t, "hello", s)
+	s, err = view.ReadCString(100)
+	require.NoError(t, err)
+	require.Equal(t, "hello", s)
+
+	_, err = view.ReadUint32(65533)
+	require.ErrorIs(t, err, ErrMemoryOutOfBounds)
+	_, err = view.ReadUint64(1 << 63)
+	require.ErrorIs(t, err, ErrMemoryOutOfBounds)
+	require.ErrorIs(t, view.WriteBytes(65535, []byte{1, 2}), ErrMemoryOutOfBounds)
+	_, err = view.ReadBytes(^uint64(0), 2)
+	require.ErrorIs(t, err, ErrMemoryOutOfBounds)
+	_, err = view.ReadCString(65536)
+	require.ErrorIs(t, err, ErrMemoryOutOfBounds)
+	require.NoError(t, view.WriteBytes(65530, []byte{1, 1, 1, 1, 1, 1}))
+	_, err = view.ReadCString(65530)
+	require.ErrorIs(t, err, ErrMemoryOutOfBounds)
+
+	// views see the memory after it's grown
+	_, err = mem.Grow(store, 1)
+	require.NoError(t, err)
+	require.NoError(t, view.WriteUint32(65536, 7))
+	val32, err = view.ReadUint32(65536)
+	require.NoError(t, err)
+	require.Equal(t, uint32(7), val32)
+}
+
+func TestMemoryView64(t *testing.T) {
+	config := NewConfig()
+	config.SetWasmMemory64(true)
+	store := NewStore(NewEngineWithConfig(config))
+	ty, err := NewMemoryType64(1, false, 0, false)
+	require.NoError(t, err)
+	mem, err := NewMemory(store, ty)
+	require.NoError(t, err)
+	view := mem.View(store)
+	require.NoError(t, view.WriteUint64(16, 42))
+	val, err := view.ReadUint64(16)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), val)
+	_, err = view.ReadUint64(1 << 40)
+	require.ErrorIs(t, err, ErrMemoryOutOfBounds)
+}
+
+func TestMemoryViewCaller(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`(module
+	  (import "" "log" (func $log (param i32 i32)))
+	  (memory (export "memory") 1)
+	  (data (i32.const 16) "from wasm")
+	  (func (export "run") (call $log (i32.const 16) (i32.const 9)))
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+
+	var logged string
+	log := WrapFunc(store, func(caller *Caller, ptr, length int32) error {
+		view := caller.GetExport("memory").Memory().View(caller)
+		s, err := view.ReadString(uint64(uint32(ptr)), uint64(uint32(length)))
+		if err != nil {
+			return err
+		}
+		logged = s
+		// out of bounds errors propagate out of wasm
+		_, err = view.ReadBytes(uint64(uint32(ptr)), 1<<20)
+		return err
+	})
+	instance, err := NewInstance(store, module, []AsExtern{log})
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "run").Call(store)
+	require.Equal(t, "from wasm", logged)
+	require.True(t, errors.Is(err, ErrMemoryOutOfBounds))
+}
@@ -0,0 +1,273 @@
+package wasmtime
+
+// #include <wasmtime.h>
+import "C"
+
+import (
+	"runtime"
+	"time"
+)
+
+// StoreMetrics is a report of the resources used within a `Store`, as returned
+// by `Store.Metrics`.
+type StoreMetrics struct {
+	// Functions are the metrics of each function invoked within the store,
+	// keyed by the function's name.
+	//
+	// Exported WebAssembly functions are named by their export name, host
+	// functions are named by the `module.name` they were imported or defined
+	// as, and the execution of start functions during instantiation is
+	// attributed to `<instantiate>`. Functions whose name isn't known are
+	// attributed to `<wasm function>` or `<host function>`.
+	Functions map[string]FunctionMetrics
+	// GuestTime is the total wall time spent executing WebAssembly.
+	GuestTime time.Duration
+	// HostTime is the total wall time spent executing host functions.
+	HostTime time.Duration
+	// Fuel is the total fuel consumed, which is only tracked if fuel
+	// consumption is enabled through `Config.SetConsumeFuel`.
+	Fuel uint64
+	// MemoryHighWater is the largest total size, in bytes, of the linear
+	// memories within the store which have been exported from an instance or
+	// created with `NewMemory`.
+	MemoryHighWater uint64
+}
+
+// FunctionMetrics are the resources used by one function, see `StoreMetrics`.
+//
+// The time and fuel of a function exclude that of the functions it calls, so a
+// WebAssembly function calling a host function isn't charged for the host
+// function's time.
+type FunctionMetrics struct {
+	// Calls is the number of times the function was invoked.
+	Calls uint64
+	// Fuel is the fuel consumed by the function.
+	Fuel uint64
+	// GuestTime is the wall time spent executing the function, if it's a
+	// WebAssembly function.
+	GuestTime time.Duration
+	// HostTime is the wall time spent executing the function, if it's a
+	// host function.
+	HostTime time.Duration
+}
+
+// The accounting state of a store with metrics enabled.
+type storeMetrics struct {
+	functions map[string]*FunctionMetrics
+
+	// names of the functions within this store, along with the host functions
+	// defined in Go and their names
+	funcNames map[C.wasmtime_func_t]string
+	hostFuncs map[C.wasmtime_func_t]hostFuncRef
+	hostNames map[hostFuncRef]string
+
+	// memories within this store whose size is tracked
+	memories map[C.wasmtime_memory_t]struct{}
+
+	// the functions currently executing, innermost last, and the time and
+	// fuel at which the innermost function started or resumed executing
+	stack        []metricsFrame
+	segmentStart time.Time
+	segmentFuel  uint64
+}
+
+// Identifies a host function defined in Go by its index, as passed to
+// `goTrampolineNew` or `goTrampolineWrap`.
+type hostFuncRef struct {
+	wrap bool
+	idx  int
+}
+
+type metricsFrame struct {
+	metrics *FunctionMetrics
+	host    bool
+}
+
+// EnableMetrics enables the accounting of the time and fuel used by each
+// function invoked within this store, as reported by `Metrics`.
+//
+// Metrics should be enabled before any functions are defined or modules are
+// instantiated within this store, as functions and memories are only named and
+// tracked as they're created once metrics are enabled.
+//
+// Note that, as with `Store.SetCallHook`, host functions implemented by
+// Wasmtime itself, such as WASI, are accounted for as part of the WebAssembly
+// function calling them.
+func (store *Store) EnableMetrics() {
+	data := getDataInStore(store)
+	if data.metrics == nil {
+		data.metrics = &storeMetrics{
+			functions: make(map[string]*FunctionMetrics),
+			funcNames: make(map[C.wasmtime_func_t]string),
+			hostFuncs: make(map[C.wasmtime_func_t]hostFuncRef),
+			hostNames: make(map[hostFuncRef]string),
+			memories:  make(map[C.wasmtime_memory_t]struct{}),
+		}
+	}
+	runtime.KeepAlive(store)
+}
+
+// Metrics returns the resources used within this store so far.
+//
+// Metrics must have been enabled with `EnableMetrics`, otherwise the returned
+// metrics are empty.
+func (store *Store) Metrics() StoreMetrics {
+	m := getDataInStore(store).metrics
+	ret := StoreMetrics{Functions: make(map[string]FunctionMetrics)}
+	if m == nil {
+		return ret
+	}
+	for name, f := range m.functions {
+		ret.Functions[name] = *f
+		ret.GuestTime += f.GuestTime
+		ret.HostTime += f.HostTime
+		ret.Fuel += f.Fuel
+	}
+	// Linear memories can't shrink, so their current size is also their
+	// high-water mark.
+	for mem := range m.memories {
+		mem := mem
+		ret.MemoryHighWater += uint64(C.wasmtime_memory_data_size(store.Context(), &mem))
+	}
+	runtime.KeepAlive(store)
+	return ret
+}
+
+// Records that the function about to be invoked, `name`, started executing,
+// returning the depth to pass to `exit` once it returns.
+func (m *storeMetrics) enter(store Storelike, name string, host bool) int {
+	now, fuel := time.Now(), metricsFuel(store)
+	depth := len(m.stack)
+	if depth > 0 {
+		m.charge(m.stack[depth-1], now, fuel)
+	}
+	f := m.functions[name]
+	if f == nil {
+		f = &FunctionMetrics{}
+		m.functions[name] = f
+	}
+	f.Calls++
+	m.stack = append(m.stack, metricsFrame{metrics: f, host: host})
+	m.segmentStart, m.segmentFuel = now, fuel
+	return depth
+}
+
+// Records that the function entered at `depth` returned, resuming the
+// execution of its caller.
+func (m *storeMetrics) exit(store Storelike, depth int) {
+	now, fuel := time.Now(), metricsFuel(store)
+	if depth < len(m.stack) {
+		m.charge(m.stack[len(m.stack)-1], now, fuel)
+		// Frames above `depth` are only left over if a panic unwound
+		// through them, so they're discarded as well.
+		m.stack = m.stack[:depth]
+	}
+	m.segmentStart, m.segmentFuel = now, fuel
+}
+
+func (m *storeMetrics) charge(frame metricsFrame, now time.Time, fuel uint64) {
+	elapsed := now.Sub(m.segmentStart)
+	if frame.host {
+		frame.metrics.HostTime += elapsed
+	} else {
+		frame.metrics.GuestTime += elapsed
+	}
+	// Fuel may have been added by the host in the meantime, in which case
+	// none is considered to have been consumed.
+	if fuel < m.segmentFuel {
+		frame.metrics.Fuel += m.segmentFuel - fuel
+	}
+}
+
+// Returns the fuel remaining in `store`, or 0 if fuel isn't enabled.
+func metricsFuel(store Storelike) uint64 {
+	var fuel C.uint64_t
+	err := C.wasmtime_context_get_fuel(store.Context(), &fuel)
+	runtime.KeepAlive(store)
+	if err != nil {
+		C.wasmtime_error_delete(err)
+		return 0
+	}
+	return uint64(fuel)
+}
+
+// Names the function `f` as `name`, unless it's already been named.
+func (m *storeMetrics) nameFunc(f *Func, name string) {
+	if ref, ok := m.hostFuncs[f.val]; ok {
+		if _, ok := m.hostNames[ref]; !ok {
+			m.hostNames[ref] = name
+		}
+		return
+	}
+	if _, ok := m.funcNames[f.val]; !ok {
+		m.funcNames[f.val] = name
+	}
+}
+
+// Returns the name of the WebAssembly function `f`, or `<instantiate>` if
+// it's nil.
+func (m *storeMetrics) wasmFuncName(f *C.wasmtime_func_t) string {
+	if f == nil {
+		return "<instantiate>"
+	}
+	if name, ok := m.funcNames[*f]; ok {
+		return name
+	}
+	return "<wasm function>"
+}
+
+// Returns the name of the host function `ref`, falling back to the name it
+// was defined with in a `Linker`, if any.
+func (m *storeMetrics) hostFuncName(ref hostFuncRef, linkerName string) string {
+	if name, ok := m.hostNames[ref]; ok {
+		return name
+	}
+	if linkerName != "" {
+		return linkerName
+	}
+	return "<host function>"
+}
+
+// Names the imports of `module` that `imports` are used for.
+func (m *storeMetrics) recordImports(module *Module, imports []AsExtern) {
+	for i, ty := range module.Imports() {
+		if i >= len(imports) {
+			break
+		}
+		if f, ok := imports[i].(*Func); ok {
+			name := ""
+			if ty.Name() != nil {
+				name = *ty.Name()
+			}
+			m.nameFunc(f, ty.Module()+"."+name)
+		}
+	}
+}
+
+// Names the exported functions of `instance`, and tracks its exported
+// memories.
+func (m *storeMetrics) recordInstance(store Storelike, instance *Instance) {
+	var name *C.char
+	var nameLen C.size_t
+	for i := 0; ; i++ {
+		var item C.wasmtime_extern_t
+		ok := C.wasmtime_instance_export_nth(
+			store.Context(),
+			&instance.val,
+			C.size_t(i),
+			&name,
+			&nameLen,
+			&item,
+		)
+		if !ok {
+			break
+		}
+		extern := mkExtern(&item)
+		if f := extern.Func(); f != nil {
+			m.nameFunc(f, C.GoStringN(name, C.int(nameLen)))
+		} else if mem := extern.Memory(); mem != nil {
+			m.memories[mem.val] = struct{}{}
+		}
+	}
+	runtime.KeepAlive(store)
+}
@@ -0,0 +1,93 @@
+package wasmtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreMetrics(t *testing.T) {
+	config := NewConfig()
+	config.SetConsumeFuel(true)
+	store := NewStore(NewEngineWithConfig(config))
+	store.EnableMetrics()
+	require.NoError(t, store.SetFuel(100000))
+
+	wasm, err := Wat2Wasm(`(module
+	  (import "env" "sleep" (func $sleep))
+	  (memory (export "memory") 2)
+	  (func (export "run")
+	    (local i32)
+	    (loop
+	      (local.set 0 (i32.add (local.get 0) (i32.const 1)))
+	      (br_if 0 (i32.lt_u (local.get 0) (i32.const 100))))
+	    call $sleep)
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	sleep := WrapFunc(store, func() {
+		time.Sleep(10 * time.Millisecond)
+	})
+	instance, err := NewInstance(store, module, []AsExtern{sleep})
+	require.NoError(t, err)
+
+	run := instance.GetFunc(store, "run")
+	_, err = run.Call(store)
+	require.NoError(t, err)
+	_, err = run.Call(store)
+	require.NoError(t, err)
+
+	metrics := store.Metrics()
+	require.Equal(t, uint64(2), metrics.Functions["run"].Calls)
+	require.Equal(t, uint64(2), metrics.Functions["env.sleep"].Calls)
+	require.Greater(t, metrics.Functions["run"].Fuel, uint64(200))
+	require.Zero(t, metrics.Functions["env.sleep"].Fuel)
+	require.GreaterOrEqual(t, metrics.Functions["env.sleep"].HostTime, 20*time.Millisecond)
+	require.Zero(t, metrics.Functions["env.sleep"].GuestTime)
+	require.Zero(t, metrics.Functions["run"].HostTime)
+	require.Equal(t, metrics.Functions["env.sleep"].HostTime, metrics.HostTime)
+
+	fuel, err := store.GetFuel()
+	require.NoError(t, err)
+	require.Equal(t, uint64(100000)-fuel, metrics.Fuel)
+	require.Equal(t, uint64(2*65536), metrics.MemoryHighWater)
+
+	_, err = instance.GetExport(store, "memory").Memory().Grow(store, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3*65536), store.Metrics().MemoryHighWater)
+}
+
+func TestStoreMetricsLinker(t *testing.T) {
+	store := NewStore(NewEngine())
+	store.EnableMetrics()
+	linker := NewLinker(store.Engine)
+	require.NoError(t, linker.FuncWrap("env", "f", func() {}))
+	require.NoError(t, linker.DefineFunc(store, "env", "g", func() {}))
+
+	wasm, err := Wat2Wasm(`(module
+	  (import "env" "f" (func $f))
+	  (import "env" "g" (func $g))
+	  (func $start call $f call $g)
+	  (start $start)
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	_, err = linker.Instantiate(store, module)
+	require.NoError(t, err)
+
+	metrics := store.Metrics()
+	require.Equal(t, uint64(1), metrics.Functions["<instantiate>"].Calls)
+	require.Equal(t, uint64(1), metrics.Functions["env.f"].Calls)
+	require.Equal(t, uint64(1), metrics.Functions["env.g"].Calls)
+}
+
+func TestStoreMetricsDisabled(t *testing.T) {
+	store := NewStore(NewEngine())
+	f := WrapFunc(store, func() {})
+	_, err := f.Call(store)
+	require.NoError(t, err)
+	require.Empty(t, store.Metrics().Functions)
+}
@@ -5,7 +5,12 @@ package wasmtime
 import "C"
 
 import (
+	"bytes"
+	"debug/elf"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"unsafe"
 )
 
@@ -14,6 +19,10 @@ import (
 // Modules organized WebAssembly programs as the unit of deployment, loading, and compilation.
 type Module struct {
 	_ptr *C.wasmtime_module_t
+
+	// DWARF debug information found in the original wasm binary, if any,
+	// used to resolve `Frame.SourceLocation`.
+	debugInfo *moduleDebugInfo
 }
 
 func mkModule(ptr *C.wasmtime_module_t) *Module {
@@ -64,6 +73,96 @@ func (m *Module) Exports() []*ExportType {
 	return exports.mkGoList()
 }
 
+// ImageRange returns the range of addresses, `[start, end)`, in memory of the
+// compiled image of this module, which contains its executable code along with
+// associated data and metadata.
+//
+// This can be used, for example, to track the size of the code generated for
+// a module, or to correlate native addresses such as those of a profiler with
+// this module.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Module.html#method.image_range
+func (m *Module) ImageRange() (uintptr, uintptr) {
+	start, end := m.imageRange()
+	return uintptr(start), uintptr(end)
+}
+
+func (m *Module) imageRange() (unsafe.Pointer, unsafe.Pointer) {
+	var start, end unsafe.Pointer
+	C.wasmtime_module_image_range(m.ptr(), &start, &end)
+	runtime.KeepAlive(m)
+	return start, end
+}
+
+// CompiledFunction describes the native code compiled for one function of a
+// `Module`, as returned by `Module.CompiledFunctions`.
+type CompiledFunction struct {
+	// Index is the index of the function within the module's function index
+	// space, which includes imported functions.
+	Index uint32
+	// Name is the name of the function's symbol in the compiled image, such
+	// as `wasm[0]::function[3]`.
+	Name string
+	// Offset is the offset of the function's code relative to the start of
+	// the module's image, see `Module.ImageRange`.
+	Offset uint64
+	// Size is the size in bytes of the function's code.
+	Size uint64
+}
+
+var compiledFunctionSymbol = regexp.MustCompile(`^wasm\[\d+\]::function\[(\d+)\]`)
+
+// CompiledFunctions returns the code compiled for each function defined by
+// this module, ordered by their offsets in the module's image.
+//
+// The functions are found through the symbols of the module's compiled
+// image, which is an ELF object, and an error is returned if it can't be
+// parsed. This relies on Wasmtime naming those symbols `wasm[N]::function[M]`,
+// which is an implementation detail rather than a stable API, so a future
+// version of Wasmtime may cause this to return no functions.
+//
+// TODO: add a `DisassembleFunction` method once the C API exposes Wasmtime's
+// disassembly of compiled code; for now the code of a function can be read
+// from its range within `ImageRange` and passed to an external disassembler.
+func (m *Module) CompiledFunctions() ([]CompiledFunction, error) {
+	// The image is read in place rather than copied, as it may be larger
+	// than `C.GoBytes` can copy, so `m` is kept alive until it's parsed.
+	start, end := m.imageRange()
+	image := unsafe.Slice((*byte)(start), uintptr(end)-uintptr(start))
+	defer runtime.KeepAlive(m)
+
+	obj, err := elf.NewFile(bytes.NewReader(image))
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	symbols, err := obj.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	var ret []CompiledFunction
+	for _, sym := range symbols {
+		match := compiledFunctionSymbol.FindStringSubmatch(sym.Name)
+		if match == nil || int(sym.Section) >= len(obj.Sections) {
+			continue
+		}
+		index, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil {
+			continue
+		}
+		section := obj.Sections[sym.Section]
+		ret = append(ret, CompiledFunction{
+			Index:  uint32(index),
+			Name:   sym.Name,
+			Offset: section.Offset + sym.Value - section.Addr,
+			Size:   sym.Size,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Offset < ret[j].Offset })
+	return ret, nil
+}
+
 type importTypeList struct {
 	vec C.wasm_importtype_vec_t
 }
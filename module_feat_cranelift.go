@@ -29,7 +29,18 @@ func NewModule(engine *Engine, wasm []byte) (*Module, error) {
 		return nil, mkError(err)
 	}
 
-	return mkModule(ptr), nil
+	module := mkModule(ptr)
+	if engine.debugInfo {
+		module.debugInfo = parseModuleDebugInfo(wasm)
+	}
+	if module.debugInfo != nil {
+		for _, imp := range module.Imports() {
+			if imp.Type().FuncType() != nil {
+				module.debugInfo.importedFuncs++
+			}
+		}
+	}
+	return module, nil
 }
 
 // ModuleValidate validates whether `wasm` would be a valid wasm module according to the
@@ -121,3 +121,31 @@ func TestModuleSerialize(t *testing.T) {
 	_, err = NewModuleDeserializeFile(engine, tmpfile.Name())
 	require.NoError(t, err)
 }
+
+func TestModuleCompiledFunctions(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`(module
+	  (import "" "f" (func))
+	  (func (export "a") (result i32) i32.const 1)
+	  (func (export "b") (param i32) (result i32)
+	    local.get 0
+	    i32.const 2
+	    i32.mul)
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	start, end := module.ImageRange()
+	require.Less(t, start, end)
+
+	funcs, err := module.CompiledFunctions()
+	require.NoError(t, err)
+	require.Len(t, funcs, 2)
+	indices := []uint32{funcs[0].Index, funcs[1].Index}
+	require.ElementsMatch(t, []uint32{1, 2}, indices)
+	for _, f := range funcs {
+		require.Greater(t, f.Size, uint64(0))
+		require.LessOrEqual(t, f.Offset+f.Size, uint64(end-start))
+	}
+}
@@ -0,0 +1,157 @@
+package wasmtime
+
+// #include <wasmtime.h>
+// #include <stdlib.h>
+import "C"
+
+import (
+	"io"
+	"runtime"
+	"time"
+	"unsafe"
+)
+
+// GuestProfiler collects basic profiling data for WebAssembly guests in a
+// cross-platform way.
+//
+// Samples are taken with `Sample`, typically from a callback configured with
+// `Store.SetEpochDeadlineCallback` so that a sample is taken at every epoch
+// tick. Once profiling is done `Finish` writes out the collected profile in
+// the format of the Firefox profiler, which can be viewed at
+// https://profiler.firefox.com.
+//
+// TODO: profiles can't be converted to the `pprof` format yet. That requires
+// parsing the Firefox profiler's format, which isn't stable, so it's left
+// until Wasmtime can produce `pprof` profiles itself.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.GuestProfiler.html
+type GuestProfiler struct {
+	_ptr       *C.wasmtime_guestprofiler_t
+	store      *Store
+	lastSample time.Time
+}
+
+// GuestProfilerModule is a module whose functions are to be resolved by name
+// in the profile generated by a `GuestProfiler`.
+type GuestProfilerModule struct {
+	// Name is the name the module is displayed under in the profile.
+	Name string
+	// Module is the module whose code is being profiled.
+	Module *Module
+}
+
+// NewGuestProfiler creates a new profiler for WebAssembly executing within
+// `store`.
+//
+// The `modules` are the modules whose functions should be named in the
+// profile, and the first module's name is used as the name of the profile.
+// The `interval` is the expected interval between samples, which is only used
+// as a hint when displaying the profile.
+func NewGuestProfiler(store *Store, modules []GuestProfilerModule, interval time.Duration) *GuestProfiler {
+	profileName := "wasm"
+	if len(modules) > 0 {
+		profileName = modules[0].Name
+	}
+	nameVec := stringToByteVec(profileName)
+
+	// The list of modules, and their names, are allocated with `malloc` as
+	// the C API takes pointers to them which can't point into Go memory.
+	var rawPtr *C.wasmtime_guestprofiler_modules_t
+	var names []C.wasm_name_t
+	if len(modules) > 0 {
+		var rawModule C.wasmtime_guestprofiler_modules_t
+		var rawName C.wasm_name_t
+		rawPtr = (*C.wasmtime_guestprofiler_modules_t)(C.malloc(C.size_t(unsafe.Sizeof(rawModule) * uintptr(len(modules)))))
+		raw := unsafe.Slice(rawPtr, len(modules))
+		names = unsafe.Slice((*C.wasm_name_t)(C.malloc(C.size_t(unsafe.Sizeof(rawName)*uintptr(len(modules))))), len(modules))
+		for i, module := range modules {
+			names[i] = stringToByteVec(module.Name)
+			raw[i].name = &names[i]
+			raw[i].mod = module.Module.ptr()
+		}
+	}
+
+	ptr := C.wasmtime_guestprofiler_new(
+		store.Engine.ptr(),
+		&nameVec,
+		C.uint64_t(interval.Nanoseconds()),
+		rawPtr,
+		C.size_t(len(modules)),
+	)
+	runtime.KeepAlive(store)
+	runtime.KeepAlive(modules)
+	C.wasm_byte_vec_delete(&nameVec)
+	for i := range names {
+		C.wasm_byte_vec_delete(&names[i])
+	}
+	if rawPtr != nil {
+		C.free(unsafe.Pointer(rawPtr))
+		C.free(unsafe.Pointer(&names[0]))
+	}
+
+	profiler := &GuestProfiler{
+		_ptr:       ptr,
+		store:      store,
+		lastSample: time.Now(),
+	}
+	runtime.SetFinalizer(profiler, func(profiler *GuestProfiler) {
+		profiler.Close()
+	})
+	return profiler
+}
+
+func (p *GuestProfiler) ptr() *C.wasmtime_guestprofiler_t {
+	ret := p._ptr
+	if ret == nil {
+		panic("object has been closed already")
+	}
+	maybeGC()
+	return ret
+}
+
+// Sample adds a sample of the current WebAssembly stack of the profiled store
+// to the profile.
+//
+// The time elapsed since the previous sample, or since the profiler was
+// created, is recorded as the duration of this sample.
+func (p *GuestProfiler) Sample() {
+	now := time.Now()
+	delta := now.Sub(p.lastSample)
+	p.lastSample = now
+	C.wasmtime_guestprofiler_sample(p.ptr(), p.store.ptr(), C.uint64_t(delta.Nanoseconds()))
+	runtime.KeepAlive(p)
+	runtime.KeepAlive(p.store)
+}
+
+// Finish writes the collected profile to `w` as JSON in the format of the
+// Firefox profiler.
+//
+// The profiler is consumed by this method and cannot be used afterwards.
+func (p *GuestProfiler) Finish(w io.Writer) error {
+	runtime.SetFinalizer(p, nil)
+	ptr := p.ptr()
+	p._ptr = nil
+	retVec := C.wasm_byte_vec_t{}
+	err := C.wasmtime_guestprofiler_finish(ptr, &retVec)
+	if err != nil {
+		return mkError(err)
+	}
+	ret := C.GoBytes(unsafe.Pointer(retVec.data), C.int(retVec.size))
+	C.wasm_byte_vec_delete(&retVec)
+	_, writeErr := w.Write(ret)
+	return writeErr
+}
+
+// Close will deallocate this profiler's state explicitly, discarding any
+// collected samples.
+//
+// For more information see the documentation for engine.Close()
+func (p *GuestProfiler) Close() {
+	if p._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(p, nil)
+	C.wasmtime_guestprofiler_delete(p._ptr)
+	p._ptr = nil
+}
@@ -0,0 +1,110 @@
+package wasmtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuestProfiler(t *testing.T) {
+	config := NewConfig()
+	config.SetEpochInterruption(true)
+	engine := NewEngineWithConfig(config)
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (import "" "tick" (func $tick))
+	    (func $work (export "run")
+	      (local $i i32)
+	      (loop $l
+	        call $tick
+	        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+	        (br_if $l (i32.lt_u (local.get $i) (i32.const 10)))))
+	  )
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	store := NewStore(engine)
+	profiler := NewGuestProfiler(store, []GuestProfilerModule{{"main", module}}, time.Millisecond)
+	samples := 0
+	store.SetEpochDeadline(1)
+	store.SetEpochDeadlineCallback(func() (uint64, error) {
+		samples++
+		profiler.Sample()
+		return 1, nil
+	})
+
+	tick := WrapFunc(store, func() { engine.IncrementEpoch() })
+	instance, err := NewInstance(store, module, []AsExtern{tick})
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "run").Call(store)
+	require.NoError(t, err)
+	require.Greater(t, samples, 0)
+
+	var out bytes.Buffer
+	require.NoError(t, profiler.Finish(&out))
+	var profile map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &profile))
+	require.Contains(t, profile, "meta")
+}
+
+func TestEpochDeadlineCallbackError(t *testing.T) {
+	config := NewConfig()
+	config.SetEpochInterruption(true)
+	engine := NewEngineWithConfig(config)
+	wasm, err := Wat2Wasm(`(module (func (export "run") (loop br 0)))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	store := NewStore(engine)
+	store.SetEpochDeadline(1)
+	calls := 0
+	errOutOfTime := errors.New("out of time")
+	store.SetEpochDeadlineCallback(func() (uint64, error) {
+		calls++
+		if calls == 3 {
+			return 0, errOutOfTime
+		}
+		return 0, nil
+	})
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	engine.IncrementEpoch()
+	_, err = instance.GetFunc(store, "run").Call(store)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of time")
+	require.ErrorIs(t, err, errOutOfTime)
+	require.IsType(t, &HostError{}, err)
+	require.Equal(t, 3, calls)
+}
+
+func TestEpochDeadlineCallbackClear(t *testing.T) {
+	config := NewConfig()
+	config.SetEpochInterruption(true)
+	engine := NewEngineWithConfig(config)
+	wasm, err := Wat2Wasm(`(module (func (export "run") (loop br 0)))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	store := NewStore(engine)
+	store.SetEpochDeadline(1)
+	store.SetEpochDeadlineCallback(func() (uint64, error) {
+		return 0, errors.New("unexpected")
+	})
+	store.SetEpochDeadlineCallback(nil)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	engine.IncrementEpoch()
+	_, err = instance.GetFunc(store, "run").Call(store)
+	var trap *Trap
+	require.ErrorAs(t, err, &trap)
+	require.Equal(t, Interrupt, *trap.Code())
+	require.NotEmpty(t, trap.Frames())
+}
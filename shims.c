@@ -63,6 +63,20 @@ wasmtime_error_t *go_linker_define_func(
   return wasmtime_linker_define_func(linker, module, module_len, name, name_len, ty, cb, (void*) env, finalizer);
 }
 
+static wasmtime_error_t* epoch_deadline_callback(
+    wasmtime_context_t *context,
+    void *data,
+    uint64_t *epoch_deadline_delta,
+    wasmtime_update_deadline_kind_t *update_kind
+) {
+  *update_kind = WASMTIME_UPDATE_DEADLINE_CONTINUE;
+  return goEpochDeadlineCallback(context, epoch_deadline_delta);
+}
+
+void go_store_epoch_deadline_callback(wasmtime_store_t *store) {
+  wasmtime_store_epoch_deadline_callback(store, epoch_deadline_callback, NULL, NULL);
+}
+
 bool go_externref_new(wasmtime_context_t *cx, size_t env, wasmtime_externref_t *ref) {
   return wasmtime_externref_new(cx, (void*) env, goFinalizeExternref, ref);
 }
@@ -72,3 +86,40 @@ bool go_externref_new(wasmtime_context_t *cx, size_t env, wasmtime_externref_t *
   void go_##name##_##field##_set(name##_t *val, ty i) { val->of.field = i; }
 
 EACH_UNION_ACCESSOR(UNION_ACCESSOR)
+
+static uint8_t *linear_memory_get(void *env, size_t *byte_size, size_t *byte_capacity) {
+  return goLinearMemoryGet((size_t) env, byte_size, byte_capacity);
+}
+
+static wasmtime_error_t *linear_memory_grow(void *env, size_t new_size) {
+  return goLinearMemoryGrow((size_t) env, new_size);
+}
+
+static wasmtime_error_t *memory_creator_new(
+    void *env,
+    const wasm_memorytype_t *ty,
+    size_t minimum,
+    size_t maximum,
+    size_t reserved_size_in_bytes,
+    size_t guard_size_in_bytes,
+    wasmtime_linear_memory_t *memory_ret
+) {
+  size_t memory = 0;
+  wasmtime_error_t *err = goMemoryCreatorNew((size_t) env, (wasm_memorytype_t*) ty,
+      minimum, maximum, reserved_size_in_bytes, guard_size_in_bytes, &memory);
+  if (err != NULL)
+    return err;
+  memory_ret->env = (void*) memory;
+  memory_ret->get_memory = linear_memory_get;
+  memory_ret->grow_memory = linear_memory_grow;
+  memory_ret->finalizer = goFinalizeHostObject;
+  return NULL;
+}
+
+void go_config_host_memory_creator_set(wasm_config_t *config, size_t env) {
+  wasmtime_memory_creator_t creator;
+  creator.env = (void*) env;
+  creator.new_memory = memory_creator_new;
+  creator.finalizer = goFinalizeHostObject;
+  wasmtime_config_host_memory_creator_set(config, &creator);
+}
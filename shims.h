@@ -13,6 +13,7 @@ wasmtime_error_t *go_linker_define_func(
     int wrap,
     size_t env
 );
+void go_store_epoch_deadline_callback(wasmtime_store_t *store);
 bool go_externref_new(wasmtime_context_t *cx, size_t env, wasmtime_externref_t *ref);
 
 #define EACH_UNION_ACCESSOR(name) \
@@ -26,7 +27,8 @@ bool go_externref_new(wasmtime_context_t *cx, size_t env, wasmtime_externref_t *
   UNION_ACCESSOR(wasmtime_extern, func, wasmtime_func_t) \
   UNION_ACCESSOR(wasmtime_extern, memory, wasmtime_memory_t) \
   UNION_ACCESSOR(wasmtime_extern, table, wasmtime_table_t) \
-  UNION_ACCESSOR(wasmtime_extern, global, wasmtime_global_t)
+  UNION_ACCESSOR(wasmtime_extern, global, wasmtime_global_t) \
+  UNION_ACCESSOR(wasmtime_extern, tag, wasmtime_tag_t)
 
 #define UNION_ACCESSOR(name, field, ty) \
   ty go_##name##_##field##_get(const name##_t *val); \
@@ -35,3 +37,4 @@ bool go_externref_new(wasmtime_context_t *cx, size_t env, wasmtime_externref_t *
 EACH_UNION_ACCESSOR(UNION_ACCESSOR)
 
 #undef UNION_ACCESSOR
+void go_config_host_memory_creator_set(wasm_config_t *config, size_t env);
@@ -1,12 +1,15 @@
 package wasmtime
 
 // #include <wasmtime.h>
+// #include <stdlib.h>
 // #include "shims.h"
 import "C"
 
 import (
+	"errors"
 	"reflect"
 	"runtime"
+	"strconv"
 	"sync"
 	"unsafe"
 )
@@ -53,6 +56,36 @@ type storeData struct {
 	funcWrap  []funcWrapEntry
 	lastPanic interface{}
 
+	// error returned from a host function which isn't a `*Trap`, to be
+	// returned from `enterWasm` as a `*HostError`
+	lastError error
+
+	// callback configured with `SetEpochDeadlineCallback`, and whether the
+	// deadline was reached after it was cleared, which is then reported by
+	// `enterWasm` as an interrupt
+	epochDeadlineCallback func() (uint64, error)
+	epochInterrupted      bool
+
+	// hook configured with `SetCallHook`, along with the store it's passed
+	callHook func(Storelike, CallHookKind) error
+	store    *C.wasmtime_store_t
+
+	// accounting enabled with `EnableMetrics`
+	metrics *storeMetrics
+
+	// debug information of modules used within this store, for resolving the
+	// source locations of trap frames
+	debugModules []*moduleDebugInfo
+
+	// items created within this store for the templates of linkers, and the
+	// clones of those linkers with the items defined, keyed by `Linker.id`,
+	// along with outdated clones to delete once no instantiation with a clone
+	// is in progress
+	templates    map[*linkerTemplate]AsExtern
+	linkers      map[uint64]storeLinker
+	staleLinkers []*C.wasmtime_linker_t
+	linkersInUse int
+
 	// arbitrary data supplied by the embedder
 	data interface{}
 }
@@ -60,10 +93,14 @@ type storeData struct {
 type funcNewEntry struct {
 	callback func(*Caller, []Val) ([]Val, *Trap)
 	results  []*ValType
+	// `module.name` that this function was defined as in a `Linker`, if any
+	name string
 }
 
 type funcWrapEntry struct {
 	callback reflect.Value
+	// `module.name` that this function was defined as in a `Linker`, if any
+	name string
 }
 
 // NewStore creates a new `Store` from the configuration provided in `engine`
@@ -78,10 +115,12 @@ func NewStoreWithData(engine *Engine, data interface{}) *Store {
 	// the store.
 	gStoreLock.Lock()
 	idx := gStoreSlab.allocate()
-	gStoreMap[idx] = &storeData{engine: engine, data: data}
+	storeData := &storeData{engine: engine, data: data}
+	gStoreMap[idx] = storeData
 	gStoreLock.Unlock()
 
 	ptr := C.go_store_new(engine.ptr(), C.size_t(idx))
+	storeData.store = ptr
 	store := &Store{
 		_ptr:   ptr,
 		Engine: engine,
@@ -92,6 +131,21 @@ func NewStoreWithData(engine *Engine, data interface{}) *Store {
 	return store
 }
 
+// Records that `module` is used within this store so that traps can resolve
+// source locations through its debug information.
+func (data *storeData) useModule(module *Module) {
+	info := module.debugInfo
+	if info == nil {
+		return
+	}
+	for _, other := range data.debugModules {
+		if other == info {
+			return
+		}
+	}
+	data.debugModules = append(data.debugModules, info)
+}
+
 //export goFinalizeStore
 func goFinalizeStore(env unsafe.Pointer) {
 	// When a store is finalized this is used as the finalization callback for the
@@ -100,9 +154,19 @@ func goFinalizeStore(env unsafe.Pointer) {
 	// a future store.
 	idx := int(uintptr(env))
 	gStoreLock.Lock()
-	defer gStoreLock.Unlock()
+	data := gStoreMap[idx]
 	delete(gStoreMap, idx)
 	gStoreSlab.deallocate(idx)
+	gStoreLock.Unlock()
+
+	if data != nil {
+		for _, linker := range data.linkers {
+			C.wasmtime_linker_delete(linker.ptr)
+		}
+		for _, ptr := range data.staleLinkers {
+			C.wasmtime_linker_delete(ptr)
+		}
+	}
 }
 
 func (store *Store) ptr() *C.wasmtime_store_t {
@@ -157,10 +221,144 @@ func (store *Store) SetEpochDeadline(deadline uint64) {
 	runtime.KeepAlive(store)
 }
 
+// SetEpochDeadlineCallback configures a callback to be invoked whenever this
+// store's epoch deadline is reached, instead of interrupting WebAssembly with
+// a trap.
+//
+// The callback returns the number of ticks beyond the engine's current epoch
+// at which the next deadline is set, after which execution resumes. If the
+// callback instead returns an error then WebAssembly traps with that error,
+// which is returned as a `*HostError` as for host functions. This can be used, for example, to periodically take samples with a
+// `GuestProfiler`.
+//
+// If `cb` is nil then any previously configured callback is removed, and
+// reaching the deadline interrupts WebAssembly with a trap again.
+//
+// Note that epoch interruption must be enabled through
+// `Config.SetEpochInterruption` for this callback to be invoked.
+func (store *Store) SetEpochDeadlineCallback(cb func() (uint64, error)) {
+	getDataInStore(store).epochDeadlineCallback = cb
+	if cb != nil {
+		C.go_store_epoch_deadline_callback(store.ptr())
+		runtime.KeepAlive(store)
+	}
+}
+
+//export goEpochDeadlineCallback
+func goEpochDeadlineCallback(context *C.wasmtime_context_t, delta *C.uint64_t) *C.wasmtime_error_t {
+	data := getDataInContext(context)
+	if data.epochDeadlineCallback == nil {
+		// The C API has no means of removing the callback, so instead fail
+		// here and have `enterWasm` report the interrupt.
+		data.epochInterrupted = true
+		msg := C.CString("epoch deadline reached")
+		defer C.free(unsafe.Pointer(msg))
+		return C.wasmtime_error_new(msg)
+	}
+	var next uint64
+	var err error
+	var lastPanic interface{}
+	func() {
+		defer func() { lastPanic = recover() }()
+		next, err = data.epochDeadlineCallback()
+	}()
+	if lastPanic != nil {
+		data.lastPanic = lastPanic
+		err = errors.New("go panicked")
+	} else if err != nil {
+		data.lastError = err
+	}
+	if err != nil {
+		msg := C.CString(err.Error())
+		defer C.free(unsafe.Pointer(msg))
+		return C.wasmtime_error_new(msg)
+	}
+	*delta = C.uint64_t(next)
+	return nil
+}
+
+// CallHookKind is the kind of transition between WebAssembly and the host that
+// a hook configured with `Store.SetCallHook` is invoked for.
+type CallHookKind uint8
+
+const (
+	// CallingWasm: the host is about to call into WebAssembly.
+	CallingWasm CallHookKind = iota
+	// ReturningFromWasm: WebAssembly has returned to the host.
+	ReturningFromWasm
+	// CallingHost: WebAssembly is about to call a host function.
+	CallingHost
+	// ReturningFromHost: a host function is about to return to WebAssembly.
+	ReturningFromHost
+)
+
+func (kind CallHookKind) String() string {
+	switch kind {
+	case CallingWasm:
+		return "CallingWasm"
+	case ReturningFromWasm:
+		return "ReturningFromWasm"
+	case CallingHost:
+		return "CallingHost"
+	case ReturningFromHost:
+		return "ReturningFromHost"
+	}
+	return "CallHookKind(" + strconv.Itoa(int(kind)) + ")"
+}
+
+// SetCallHook configures a hook to be invoked on every transition between
+// WebAssembly and the host within this store, or removes the hook if `hook`
+// is nil.
+//
+// This can be used, for example, to measure the time spent in WebAssembly
+// and in the host. If the hook returns an error then execution is aborted
+// with a trap: with `CallingWasm` and `ReturningFromWasm` the error is
+// returned from the invocation of WebAssembly, such as `Func.Call`, and
+// otherwise it's returned as if the host function itself had failed with it.
+//
+// The hook is given the store it's invoked within, which can be used to
+// access the store but not to close it.
+//
+// Note that hooks are only invoked for host functions defined in Go, such as
+// with `NewFunc` or `WrapFunc`, and not for those implemented by Wasmtime
+// itself such as WASI.
+func (store *Store) SetCallHook(hook func(store Storelike, kind CallHookKind) error) {
+	getDataInStore(store).callHook = hook
+	runtime.KeepAlive(store)
+}
+
+// Invokes the hook configured with `SetCallHook`, if any, for `kind`.
+func (data *storeData) invokeCallHook(kind CallHookKind) error {
+	if data.callHook == nil {
+		return nil
+	}
+	// Holding onto the `*Store` that the hook was configured on here would
+	// keep it from ever being finalized, so the hook is instead given a
+	// reference which doesn't own the underlying store.
+	return data.callHook(storeRef{data.store}, kind)
+}
+
+// A `Storelike` referring to a store it doesn't own, and so can't close.
+type storeRef struct {
+	ptr *C.wasmtime_store_t
+}
+
+func (s storeRef) Context() *C.wasmtime_context_t {
+	return C.wasmtime_store_context(s.ptr)
+}
+
+func (s storeRef) Data() interface{} {
+	return getDataInStore(s).data
+}
+
 // Returns the underlying `*storeData` that this store references in Go, used
 // for inserting functions or storing panic data.
 func getDataInStore(store Storelike) *storeData {
-	data := uintptr(C.wasmtime_context_get_data(store.Context()))
+	return getDataInContext(store.Context())
+}
+
+func getDataInContext(context *C.wasmtime_context_t) *storeData {
+	data := uintptr(C.wasmtime_context_get_data(context))
 	gStoreLock.Lock()
 	defer gStoreLock.Unlock()
 	return gStoreMap[int(data)]
@@ -174,11 +372,12 @@ var (
 	gEngineFuncWrapSlab slab
 )
 
-func insertFuncNew(data *storeData, ty *FuncType, callback func(*Caller, []Val) ([]Val, *Trap)) int {
+func insertFuncNew(data *storeData, ty *FuncType, callback func(*Caller, []Val) ([]Val, *Trap), name string) int {
 	var idx int
 	entry := funcNewEntry{
 		callback: callback,
 		results:  ty.Results(),
+		name:     name,
 	}
 	if data == nil {
 		gEngineFuncLock.Lock()
@@ -204,9 +403,9 @@ func (data *storeData) getFuncNew(idx int) *funcNewEntry {
 	}
 }
 
-func insertFuncWrap(data *storeData, callback reflect.Value) int {
+func insertFuncWrap(data *storeData, callback reflect.Value, name string) int {
 	var idx int
-	entry := funcWrapEntry{callback}
+	entry := funcWrapEntry{callback, name}
 	if data == nil {
 		gEngineFuncLock.Lock()
 		defer gEngineFuncLock.Unlock()
@@ -8,7 +8,8 @@ import "runtime"
 // `Store`.
 //
 // The `wasi` argument cannot be reused for another `Store`, it's consumed by
-// this function.
+// this function. Use `WasiConfig.Clone` to configure multiple stores from the
+// same configuration.
 func (store *Store) SetWasi(wasi *WasiConfig) {
 	runtime.SetFinalizer(wasi, nil)
 	ptr := wasi.ptr()
@@ -1,6 +1,7 @@
 package wasmtime
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -157,3 +158,82 @@ func TestStoreData(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, called, "expected wrapped func to be called")
 }
+
+func TestCallHook(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`(module
+	  (import "" "f" (func $f))
+	  (func (export "run") call $f)
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+
+	var events []string
+	f := WrapFunc(store, func() {
+		events = append(events, "host")
+	})
+	instance, err := NewInstance(store, module, []AsExtern{f})
+	require.NoError(t, err)
+	run := instance.GetFunc(store, "run")
+
+	store.SetCallHook(func(s Storelike, kind CallHookKind) error {
+		require.NotNil(t, s.Context())
+		events = append(events, kind.String())
+		return nil
+	})
+	_, err = run.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"CallingWasm",
+		"CallingHost",
+		"host",
+		"ReturningFromHost",
+		"ReturningFromWasm",
+	}, events)
+
+	store.SetCallHook(nil)
+	events = nil
+	_, err = run.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, []string{"host"}, events)
+}
+
+func TestCallHookError(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`(module
+	  (import "" "f" (func $f))
+	  (func (export "run") call $f)
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	called := false
+	f := WrapFunc(store, func() {
+		called = true
+	})
+	instance, err := NewInstance(store, module, []AsExtern{f})
+	require.NoError(t, err)
+	run := instance.GetFunc(store, "run")
+
+	errBudget := errors.New("budget exceeded")
+	store.SetCallHook(func(s Storelike, kind CallHookKind) error {
+		if kind == CallingHost {
+			return errBudget
+		}
+		return nil
+	})
+	_, err = run.Call(store)
+	require.ErrorIs(t, err, errBudget)
+	require.False(t, called)
+
+	store.SetCallHook(func(s Storelike, kind CallHookKind) error {
+		if kind == CallingWasm {
+			return errBudget
+		}
+		return nil
+	})
+	_, err = run.Call(store)
+	require.Equal(t, errBudget, err)
+	require.False(t, called)
+}
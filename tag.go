@@ -0,0 +1,29 @@
+package wasmtime
+
+// #include "shims.h"
+import "C"
+
+// Tag is an exception tag, the runtime representation of a tag definition
+// which exceptions are thrown and caught with.
+//
+// Tags exported from an instance, acquired with `Extern.Tag`, can be imported
+// by other instances or defined in a `Linker` so that those instances can
+// throw and catch the same exceptions.
+//
+// Tags can't yet be created from Go, see `Config.SetWasmExceptions`.
+//
+// For more information see the Rust documentation at
+// https://docs.wasmtime.dev/api/wasmtime/struct.Tag.html
+type Tag struct {
+	val C.wasmtime_tag_t
+}
+
+func mkTag(val C.wasmtime_tag_t) *Tag {
+	return &Tag{val}
+}
+
+func (t *Tag) AsExtern() C.wasmtime_extern_t {
+	ret := C.wasmtime_extern_t{kind: C.WASMTIME_EXTERN_TAG}
+	C.go_wasmtime_extern_tag_set(&ret, t.val)
+	return ret
+}
@@ -0,0 +1,62 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newExceptionsStore() *Store {
+	config := NewConfig()
+	config.SetWasmExceptions(true)
+	return NewStore(NewEngineWithConfig(config))
+}
+
+func TestTag(t *testing.T) {
+	store := newExceptionsStore()
+	wasm, err := Wat2Wasm(`(module
+	  (tag (export "e") (param i32))
+	  (func (export "f"))
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	defs, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+	require.Nil(t, defs.GetExport(store, "f").Tag())
+	tag := defs.GetExport(store, "e").Tag()
+	require.NotNil(t, tag)
+
+	// An imported tag can be used to catch exceptions thrown with it by other
+	// instances.
+	wasm, err = Wat2Wasm(`(module
+	  (import "" "e" (tag $e (param i32)))
+	  (func (export "throw") (param i32) (throw $e (local.get 0)))
+	)`)
+	require.NoError(t, err)
+	module, err = NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	thrower, err := NewInstance(store, module, []AsExtern{tag})
+	require.NoError(t, err)
+
+	linker := NewLinker(store.Engine)
+	require.NoError(t, linker.Define(store, "", "e", tag))
+	require.NoError(t, linker.Define(store, "", "throw", thrower.GetExport(store, "throw")))
+	wasm, err = Wat2Wasm(`(module
+	  (import "" "e" (tag $e (param i32)))
+	  (import "" "throw" (func $throw (param i32)))
+	  (func (export "catch") (param i32) (result i32)
+	    (block $handler (result i32)
+	      (try_table (catch $e $handler)
+	        (call $throw (local.get 0)))
+	      (i32.const -1)))
+	)`)
+	require.NoError(t, err)
+	module, err = NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	catcher, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	ret, err := catcher.GetFunc(store, "catch").Call(store, 7)
+	require.NoError(t, err)
+	require.Equal(t, int32(7), ret)
+}
@@ -6,22 +6,38 @@ package wasmtime
 import "C"
 
 import (
+	"fmt"
 	"runtime"
+	"strings"
 	"unsafe"
 )
 
+// TODO: expose `Config.SetCoredumpOnTrap` and a `Trap.Coredump` accessor
+// producing the standard wasm coredump format. Wasmtime's `WasmCoreDump` is
+// only reachable from Rust at the moment; the C API needs a config knob and an
+// accessor on `wasmtime_error_t` / `wasm_trap_t` before this can be bound.
+
 // Trap is the trap instruction which represents the occurrence of a trap.
 // Traps are bubbled up through nested instruction sequences, ultimately reducing the entire program to a single trap instruction, signalling abrupt termination.
 type Trap struct {
 	_ptr *C.wasm_trap_t
+
+	// debug information of the modules used within the store this trap
+	// originated from, see `Frame.SourceLocation`
+	debugModules []*moduleDebugInfo
+
+	// the error this trap was raised in place of, if any, whose backtrace is
+	// then that of the trap
+	trace *Error
 }
 
 // Frame is one of activation frames which carry the return arity n of the respective function,
 // hold the values of its locals (including arguments) in the order corresponding to their static local indices,
 // and a reference to the function’s own module instance
 type Frame struct {
-	_ptr   *C.wasm_frame_t
-	_owner interface{}
+	_ptr         *C.wasm_frame_t
+	_owner       interface{}
+	debugModules []*moduleDebugInfo
 }
 
 // TrapCode is the code of an instruction trap.
@@ -54,6 +70,56 @@ const (
 	OutOfFuel
 )
 
+// Sentinel errors for each `TrapCode`, which can be used with `errors.Is` to
+// test whether an error returned from executing WebAssembly, for example from
+// `Func.Call` or `NewInstance`, is a trap with that code.
+var (
+	ErrStackOverflow          error = StackOverflow
+	ErrMemoryOutOfBounds      error = MemoryOutOfBounds
+	ErrHeapMisaligned         error = HeapMisaligned
+	ErrTableOutOfBounds       error = TableOutOfBounds
+	ErrIndirectCallToNull     error = IndirectCallToNull
+	ErrBadSignature           error = BadSignature
+	ErrIntegerOverflow        error = IntegerOverflow
+	ErrIntegerDivisionByZero  error = IntegerDivisionByZero
+	ErrBadConversionToInteger error = BadConversionToInteger
+	ErrUnreachable            error = UnreachableCodeReached
+	ErrInterrupt              error = Interrupt
+	ErrOutOfFuel              error = OutOfFuel
+)
+
+// Error returns a description of this trap code, which allows a `TrapCode` to
+// be used as an error with `errors.Is`.
+func (code TrapCode) Error() string {
+	switch code {
+	case StackOverflow:
+		return "call stack exhausted"
+	case MemoryOutOfBounds:
+		return "out of bounds memory access"
+	case HeapMisaligned:
+		return "misaligned memory access"
+	case TableOutOfBounds:
+		return "undefined element: out of bounds table access"
+	case IndirectCallToNull:
+		return "uninitialized element"
+	case BadSignature:
+		return "indirect call type mismatch"
+	case IntegerOverflow:
+		return "integer overflow"
+	case IntegerDivisionByZero:
+		return "integer divide by zero"
+	case BadConversionToInteger:
+		return "invalid conversion to integer"
+	case UnreachableCodeReached:
+		return "wasm `unreachable` instruction executed"
+	case Interrupt:
+		return "interrupt"
+	case OutOfFuel:
+		return "all fuel consumed by WebAssembly"
+	}
+	return fmt.Sprintf("trap code %d", uint8(code))
+}
+
 // NewTrap creates a new `Trap` with the `name` and the type provided.
 func NewTrap(message string) *Trap {
 	ptr := C.wasmtime_trap_new(C._GoStringPtr(message), C._GoStringLen(message))
@@ -61,6 +127,16 @@ func NewTrap(message string) *Trap {
 	return mkTrap(ptr)
 }
 
+// NewTrapWithCode creates a new `Trap` with the `code` provided.
+//
+// When returned from a host function the trap is propagated to the caller as
+// if WebAssembly itself trapped with `code`, so it can be detected with
+// `Trap.Code` or `errors.Is`.
+func NewTrapWithCode(code TrapCode) *Trap {
+	ptr := C.wasmtime_trap_new_code(C.wasmtime_trap_code_t(code))
+	return mkTrap(ptr)
+}
+
 func mkTrap(ptr *C.wasm_trap_t) *Trap {
 	trap := &Trap{_ptr: ptr}
 	runtime.SetFinalizer(trap, func(trap *Trap) {
@@ -116,6 +192,18 @@ func (t *Trap) Error() string {
 	return t.Message()
 }
 
+// Is reports whether this trap has the code of `target`, which is expected to
+// be one of the sentinel trap errors such as `ErrOutOfFuel`. This is used by
+// `errors.Is`.
+func (t *Trap) Is(target error) bool {
+	code, ok := target.(TrapCode)
+	if !ok {
+		return false
+	}
+	actual := t.Code()
+	return actual != nil && *actual == code
+}
+
 func unwrapStrOr(s *string, other string) string {
 	if s == nil {
 		return other
@@ -132,7 +220,11 @@ type frameList struct {
 // Frames returns the wasm function frames that make up this trap
 func (t *Trap) Frames() []*Frame {
 	frames := &frameList{owner: t}
-	C.wasm_trap_trace(t.ptr(), &frames.vec)
+	if t.trace != nil {
+		C.wasmtime_error_wasm_trace(t.trace.ptr(), &frames.vec)
+	} else {
+		C.wasm_trap_trace(t.ptr(), &frames.vec)
+	}
 	runtime.KeepAlive(t)
 	runtime.SetFinalizer(frames, func(frames *frameList) {
 		C.wasm_frame_vec_delete(&frames.vec)
@@ -144,8 +236,9 @@ func (t *Trap) Frames() []*Frame {
 	for i := 0; i < int(frames.vec.size); i++ {
 		ptr := *(**C.wasm_frame_t)(unsafe.Pointer(uintptr(base) + unsafe.Sizeof(ptr)*uintptr(i)))
 		ret[i] = &Frame{
-			_ptr:   ptr,
-			_owner: frames,
+			_ptr:         ptr,
+			_owner:       frames,
+			debugModules: t.debugModules,
 		}
 	}
 	return ret
@@ -204,3 +297,73 @@ func (f *Frame) FuncOffset() uint {
 	runtime.KeepAlive(f)
 	return ret
 }
+
+// SourceLocation returns the source file, line and column of this frame's
+// instruction.
+//
+// The location is resolved through the DWARF debug information embedded in
+// the custom sections of the module that defines this frame's function, as
+// emitted by compilers such as Rust and Clang when building with debug
+// information. This requires the module to have been compiled with
+// `NewModule` by an engine with `Config.SetDebugInfo` enabled, as only then
+// is its debug information retained.
+//
+// Frames are matched to their module by `ModuleName`, so the location is
+// only resolved if no other module with debug information used within the
+// same store has the same name, where unnamed modules are all considered to
+// have the same name.
+//
+// The returned `bool` is false if the location couldn't be resolved.
+func (f *Frame) SourceLocation() (file string, line, col int, ok bool) {
+	if len(f.debugModules) == 0 {
+		return "", 0, 0, false
+	}
+	name := f.ModuleName()
+	var module *moduleDebugInfo
+	for _, info := range f.debugModules {
+		if !sameModuleName(info.name, name) {
+			continue
+		}
+		if module != nil {
+			return "", 0, 0, false
+		}
+		module = info
+	}
+	if module == nil {
+		return "", 0, 0, false
+	}
+	return module.sourceLocation(f.FuncIndex(), uint64(f.ModuleOffset()))
+}
+
+func sameModuleName(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// SourceBacktrace renders the frames of this trap in the style of a Go stack
+// trace, including the source location of each frame where one can be
+// resolved through `Frame.SourceLocation`.
+//
+// Unlike `Error`, which renders the backtrace Wasmtime itself produces, this
+// is intended for logging traps of guests compiled with debug information.
+func (t *Trap) SourceBacktrace() string {
+	var b strings.Builder
+	for _, frame := range t.Frames() {
+		name := fmt.Sprintf("<wasm function %d>", frame.FuncIndex())
+		if funcName := frame.FuncName(); funcName != nil {
+			name = *funcName
+		}
+		if moduleName := frame.ModuleName(); moduleName != nil {
+			name = *moduleName + "!" + name
+		}
+		fmt.Fprintf(&b, "%s(...)\n", name)
+		if file, line, col, ok := frame.SourceLocation(); ok {
+			fmt.Fprintf(&b, "\t%s:%d:%d +0x%x\n", file, line, col, frame.ModuleOffset())
+		} else {
+			fmt.Fprintf(&b, "\t<unknown> +0x%x\n", frame.ModuleOffset())
+		}
+	}
+	return b.String()
+}
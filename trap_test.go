@@ -1,6 +1,7 @@
 package wasmtime
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -52,6 +53,74 @@ Caused by:
 	require.Equal(t, *code, UnreachableCodeReached)
 }
 
+func TestTrapErrorsIs(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`(module
+	  (memory 1)
+	  (func (export "unreachable") unreachable)
+	  (func (export "oob") (drop (i32.load (i32.const 65536))))
+	  (func (export "div") (drop (i32.div_u (i32.const 1) (i32.const 0))))
+	)`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+
+	_, err = instance.GetFunc(store, "unreachable").Call(store)
+	require.ErrorIs(t, err, ErrUnreachable)
+	require.False(t, errors.Is(err, ErrOutOfFuel))
+
+	_, err = instance.GetFunc(store, "oob").Call(store)
+	require.ErrorIs(t, err, ErrMemoryOutOfBounds)
+
+	_, err = instance.GetFunc(store, "div").Call(store)
+	require.ErrorIs(t, err, ErrIntegerDivisionByZero)
+	require.Equal(t, "integer divide by zero", ErrIntegerDivisionByZero.Error())
+
+	require.False(t, errors.Is(NewTrap("message"), ErrUnreachable))
+}
+
+func TestTrapErrorsIsInstantiate(t *testing.T) {
+	store := NewStore(NewEngine())
+	wasm, err := Wat2Wasm(`(module (func unreachable) (start 0))`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	_, err = NewInstance(store, module, []AsExtern{})
+	require.ErrorIs(t, err, ErrUnreachable)
+}
+
+func TestTrapErrorsIsOutOfFuel(t *testing.T) {
+	config := NewConfig()
+	config.SetConsumeFuel(true)
+	store := NewStore(NewEngineWithConfig(config))
+	require.NoError(t, store.SetFuel(1000))
+	wasm, err := Wat2Wasm(`(module (func (export "loop") (loop br 0)))`)
+	require.NoError(t, err)
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "loop").Call(store)
+	require.ErrorIs(t, err, ErrOutOfFuel)
+}
+
+func TestNewTrapWithCode(t *testing.T) {
+	trap := NewTrapWithCode(IntegerOverflow)
+	code := trap.Code()
+	require.NotNil(t, code)
+	require.Equal(t, IntegerOverflow, *code)
+	require.ErrorIs(t, trap, ErrIntegerOverflow)
+
+	store := NewStore(NewEngine())
+	f := NewFunc(store, NewFuncType(nil, nil), func(*Caller, []Val) ([]Val, *Trap) {
+		return nil, NewTrapWithCode(IntegerOverflow)
+	})
+	_, err := f.Call(store)
+	require.ErrorIs(t, err, ErrIntegerOverflow)
+}
+
 func TestTrapModuleName(t *testing.T) {
 	store := NewStore(NewEngine())
 	wasm, err := Wat2Wasm(`(module $f
@@ -71,3 +140,144 @@ func TestTrapModuleName(t *testing.T) {
 	require.Len(t, frames, 1, "expected 1 frame")
 	require.Equal(t, "f", *frames[0].ModuleName(), "bad function name")
 }
+
+// Appends a custom section named `name` containing `payload` to `wasm`.
+func appendCustomSection(wasm []byte, name string, payload []byte) []byte {
+	contents := append(uleb(uint32(len(name))), name...)
+	contents = append(contents, payload...)
+	wasm = append(wasm, 0)
+	wasm = append(wasm, uleb(uint32(len(contents)))...)
+	return append(wasm, contents...)
+}
+
+func uleb(n uint32) []byte {
+	var ret []byte
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(ret, b)
+		}
+		ret = append(ret, b|0x80)
+	}
+}
+
+// Prefixes `contents` with its 32-bit DWARF unit length.
+func dwarfUnit(contents ...byte) []byte {
+	n := len(contents)
+	return append([]byte{byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}, contents...)
+}
+
+// Returns a module named `name` whose `run` export traps on line 43 column 7
+// of `main.c`, according to its DWARF debug information.
+func newDebugInfoModule(t *testing.T, name string) []byte {
+	wasm, err := Wat2Wasm(`(module ` + name + ` (func (export "run") nop unreachable))`)
+	require.NoError(t, err)
+
+	// A compile unit for `main.c` whose line table places the `unreachable`
+	// instruction, at offset 4 in the code section, on line 43 column 7.
+	abbrev := []byte{
+		1, 0x11, 0, // compile unit without children
+		0x03, 0x08, // DW_AT_name, DW_FORM_string
+		0x10, 0x17, // DW_AT_stmt_list, DW_FORM_sec_offset
+		0, 0, 0,
+	}
+	info := dwarfUnit(append(append([]byte{4, 0, 0, 0, 0, 0, 4, 1}, "main.c\x00"...), 0, 0, 0, 0)...)
+	header := []byte{1, 1, 1, 0xfb, 14, 13, 0, 1, 1, 1, 1, 0, 0, 0, 1, 0, 0, 1, 0}
+	header = append(header, "main.c\x00"...)
+	header = append(header, 0, 0, 0, 0)
+	program := []byte{
+		0, 5, 2, 0, 0, 0, 0, // DW_LNE_set_address 0
+		1,    // DW_LNS_copy
+		2, 4, // DW_LNS_advance_pc 4
+		3, 42, // DW_LNS_advance_line 42
+		5, 7, // DW_LNS_set_column 7
+		1,    // DW_LNS_copy
+		2, 2, // DW_LNS_advance_pc 2
+		0, 1, 1, // DW_LNE_end_sequence
+	}
+	lines := append([]byte{4, 0}, dwarfUnit(header...)...)
+	lines = dwarfUnit(append(lines, program...)...)
+
+	wasm = appendCustomSection(wasm, ".debug_abbrev", abbrev)
+	wasm = appendCustomSection(wasm, ".debug_info", info)
+	return appendCustomSection(wasm, ".debug_line", lines)
+}
+
+func TestTrapSourceLocation(t *testing.T) {
+	wasm := newDebugInfoModule(t, "")
+	config := NewConfig()
+	config.SetDebugInfo(true)
+	store := NewStore(NewEngineWithConfig(config))
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "run").Call(store)
+	require.Error(t, err)
+
+	trap := err.(*Trap)
+	frames := trap.Frames()
+	require.Len(t, frames, 1)
+	file, line, col, ok := frames[0].SourceLocation()
+	require.True(t, ok)
+	require.Equal(t, "main.c", file)
+	require.Equal(t, 43, line)
+	require.Equal(t, 7, col)
+	require.Contains(t, trap.SourceBacktrace(), "main.c:43:7")
+
+	// Modules without their original binary have no debug information.
+	serialized, err := module.Serialize()
+	require.NoError(t, err)
+	module, err = NewModuleDeserialize(store.Engine, serialized)
+	require.NoError(t, err)
+	store = NewStore(store.Engine)
+	instance, err = NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "run").Call(store)
+	_, _, _, ok = err.(*Trap).Frames()[0].SourceLocation()
+	require.False(t, ok)
+
+	// Debug information is only retained with `SetDebugInfo`.
+	store = NewStore(NewEngine())
+	module, err = NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err = NewInstance(store, module, []AsExtern{})
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "run").Call(store)
+	_, _, _, ok = err.(*Trap).Frames()[0].SourceLocation()
+	require.False(t, ok)
+}
+
+func TestTrapSourceLocationModules(t *testing.T) {
+	config := NewConfig()
+	config.SetDebugInfo(true)
+	engine := NewEngineWithConfig(config)
+
+	run := func(store *Store, wasm []byte) *Frame {
+		module, err := NewModule(engine, wasm)
+		require.NoError(t, err)
+		instance, err := NewInstance(store, module, []AsExtern{})
+		require.NoError(t, err)
+		_, err = instance.GetFunc(store, "run").Call(store)
+		require.Error(t, err)
+		return err.(*Trap).Frames()[0]
+	}
+
+	// Frames of modules with distinct names are resolved through their own
+	// module's debug information.
+	store := NewStore(engine)
+	run(store, newDebugInfoModule(t, "$a"))
+	frame := run(store, newDebugInfoModule(t, "$b"))
+	file, line, _, ok := frame.SourceLocation()
+	require.True(t, ok)
+	require.Equal(t, "main.c", file)
+	require.Equal(t, 43, line)
+
+	// Frames can't be matched to one of several modules with the same name.
+	store = NewStore(engine)
+	run(store, newDebugInfoModule(t, ""))
+	frame = run(store, newDebugInfoModule(t, ""))
+	_, _, _, ok = frame.SourceLocation()
+	require.False(t, ok)
+}
@@ -93,6 +93,8 @@ func mkVal(store Storelike, src *C.wasmtime_val_t) Val {
 		gExternrefLock.Lock()
 		defer gExternrefLock.Unlock()
 		return ValExternref(gExternrefMap[int(uintptr(data))-1])
+	case C.WASMTIME_EXNREF:
+		panic("exnref values can't be passed to the host")
 	}
 	panic("failed to get kind of `Val`")
 }
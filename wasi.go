@@ -5,12 +5,42 @@ package wasmtime
 import "C"
 import (
 	"errors"
+	"os"
 	"runtime"
+	"strings"
 	"unsafe"
 )
 
 type WasiConfig struct {
 	_ptr *C.wasi_config_t
+
+	// The arguments and environment configured so far. These are tracked in
+	// Go as they can't be read back out of the C API, and because the C API
+	// only supports replacing them wholesale.
+	argv      []string
+	envKeys   []string
+	envValues []string
+
+	// The remaining configuration, recorded so it can be replayed by
+	// `Clone`.
+	stdin    wasiStdio
+	stdout   wasiStdio
+	stderr   wasiStdio
+	preopens []wasiPreopen
+}
+
+// How one of the standard streams of a `WasiConfig` has been configured. The
+// zero value means that the stream hasn't been configured.
+type wasiStdio struct {
+	inherit bool
+	path    string
+}
+
+type wasiPreopen struct {
+	path      string
+	guestPath string
+	dirPerms  WasiDirPerms
+	filePerms WasiFilePerms
 }
 
 func NewWasiConfig() *WasiConfig {
@@ -45,49 +75,142 @@ func (c *WasiConfig) Close() {
 }
 
 // SetArgv will explicitly configure the argv for this WASI configuration.
-// Note that this field can only be set, it cannot be read
+//
+// This replaces any arguments previously configured. The configured arguments
+// can be read back with `Argv`.
 func (c *WasiConfig) SetArgv(argv []string) {
-	ptrs := make([]*C.char, len(argv))
-	for i, arg := range argv {
+	c.argv = append([]string(nil), argv...)
+	c.updateArgv()
+}
+
+// AppendArgs appends `args` to the argv configured for this WASI
+// configuration.
+func (c *WasiConfig) AppendArgs(args ...string) {
+	c.argv = append(c.argv, args...)
+	c.updateArgv()
+}
+
+// InheritArgv configures this WASI configuration to use the arguments of the
+// host process, as found in `os.Args`.
+//
+// Arguments can still be appended afterwards with `AppendArgs`.
+func (c *WasiConfig) InheritArgv() {
+	c.argv = append([]string(nil), os.Args...)
+	C.wasi_config_inherit_argv(c.ptr())
+	runtime.KeepAlive(c)
+}
+
+// Argv returns a copy of the arguments currently configured for this WASI
+// configuration.
+func (c *WasiConfig) Argv() []string {
+	return append([]string(nil), c.argv...)
+}
+
+func (c *WasiConfig) updateArgv() {
+	ptrs := make([]*C.char, len(c.argv))
+	for i, arg := range c.argv {
 		ptrs[i] = C.CString(arg)
 	}
 	var argvRaw **C.char
 	if len(ptrs) > 0 {
 		argvRaw = &ptrs[0]
 	}
-	C.wasi_config_set_argv(c.ptr(), C.size_t(len(argv)), argvRaw)
+	C.wasi_config_set_argv(c.ptr(), C.size_t(len(ptrs)), argvRaw)
 	runtime.KeepAlive(c)
 	for _, ptr := range ptrs {
 		C.free(unsafe.Pointer(ptr))
 	}
 }
 
-func (c *WasiConfig) InheritArgv() {
-	C.wasi_config_inherit_argv(c.ptr())
-	runtime.KeepAlive(c)
-}
-
 // SetEnv configures environment variables to be returned for this WASI configuration.
 // The pairs provided must be an iterable list of key/value pairs of environment variables.
-// Note that this field can only be set, it cannot be read
+//
+// This replaces any environment variables previously configured. The
+// configured environment can be read back with `Env`.
 func (c *WasiConfig) SetEnv(keys, values []string) {
 	if len(keys) != len(values) {
 		panic("mismatched numbers of keys and values")
 	}
-	namePtrs := make([]*C.char, len(values))
-	valuePtrs := make([]*C.char, len(values))
-	for i, key := range keys {
+	c.envKeys = append([]string(nil), keys...)
+	c.envValues = append([]string(nil), values...)
+	c.updateEnv()
+}
+
+// AddEnv adds the environment variable `key` with `value` to this WASI
+// configuration, replacing the value of `key` if it's already configured.
+func (c *WasiConfig) AddEnv(key, value string) {
+	c.addEnv(key, value)
+	c.updateEnv()
+}
+
+func (c *WasiConfig) addEnv(key, value string) {
+	for i, k := range c.envKeys {
+		if k == key {
+			c.envValues[i] = value
+			return
+		}
+	}
+	c.envKeys = append(c.envKeys, key)
+	c.envValues = append(c.envValues, value)
+}
+
+// InheritEnv configures this WASI configuration to use the environment
+// variables of the host process, as found in `os.Environ`.
+//
+// Further variables can be added afterwards with `AddEnv`.
+func (c *WasiConfig) InheritEnv() {
+	c.envKeys, c.envValues = nil, nil
+	c.inheritEnv(func(string) bool { return true })
+	C.wasi_config_inherit_env(c.ptr())
+	runtime.KeepAlive(c)
+}
+
+// InheritEnvFiltered adds the environment variables of the host process for
+// which `filter` returns true to this WASI configuration.
+//
+// Variables are added in the same manner as `AddEnv`, so any variables which
+// are already configured are retained. This can be used to only expose a
+// subset of the host's environment to WebAssembly, for example to avoid
+// leaking secrets.
+func (c *WasiConfig) InheritEnvFiltered(filter func(key string) bool) {
+	c.inheritEnv(filter)
+	c.updateEnv()
+}
+
+func (c *WasiConfig) inheritEnv(filter func(key string) bool) {
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		// Windows has special entries such as `=C:=C:\` which aren't
+		// variables that can be looked up, so skip those.
+		if key == "" || !filter(key) {
+			continue
+		}
+		c.addEnv(key, value)
+	}
+}
+
+// Env returns a copy of the environment variables currently configured for
+// this WASI configuration as a list of keys and a list of their respective
+// values.
+func (c *WasiConfig) Env() ([]string, []string) {
+	return append([]string(nil), c.envKeys...), append([]string(nil), c.envValues...)
+}
+
+func (c *WasiConfig) updateEnv() {
+	namePtrs := make([]*C.char, len(c.envKeys))
+	valuePtrs := make([]*C.char, len(c.envValues))
+	for i, key := range c.envKeys {
 		namePtrs[i] = C.CString(key)
 	}
-	for i, value := range values {
+	for i, value := range c.envValues {
 		valuePtrs[i] = C.CString(value)
 	}
 	var namesRaw, valuesRaw **C.char
-	if len(keys) > 0 {
+	if len(namePtrs) > 0 {
 		namesRaw = &namePtrs[0]
 		valuesRaw = &valuePtrs[0]
 	}
-	C.wasi_config_set_env(c.ptr(), C.size_t(len(keys)), namesRaw, valuesRaw)
+	C.wasi_config_set_env(c.ptr(), C.size_t(len(namePtrs)), namesRaw, valuesRaw)
 	runtime.KeepAlive(c)
 	for i, ptr := range namePtrs {
 		C.free(unsafe.Pointer(ptr))
@@ -95,17 +218,13 @@ func (c *WasiConfig) SetEnv(keys, values []string) {
 	}
 }
 
-func (c *WasiConfig) InheritEnv() {
-	C.wasi_config_inherit_env(c.ptr())
-	runtime.KeepAlive(c)
-}
-
 func (c *WasiConfig) SetStdinFile(path string) error {
 	pathC := C.CString(path)
 	ok := C.wasi_config_set_stdin_file(c.ptr(), pathC)
 	runtime.KeepAlive(c)
 	C.free(unsafe.Pointer(pathC))
 	if ok {
+		c.stdin = wasiStdio{path: path}
 		return nil
 	}
 
@@ -115,6 +234,7 @@ func (c *WasiConfig) SetStdinFile(path string) error {
 func (c *WasiConfig) InheritStdin() {
 	C.wasi_config_inherit_stdin(c.ptr())
 	runtime.KeepAlive(c)
+	c.stdin = wasiStdio{inherit: true}
 }
 
 func (c *WasiConfig) SetStdoutFile(path string) error {
@@ -123,6 +243,7 @@ func (c *WasiConfig) SetStdoutFile(path string) error {
 	runtime.KeepAlive(c)
 	C.free(unsafe.Pointer(pathC))
 	if ok {
+		c.stdout = wasiStdio{path: path}
 		return nil
 	}
 
@@ -132,6 +253,7 @@ func (c *WasiConfig) SetStdoutFile(path string) error {
 func (c *WasiConfig) InheritStdout() {
 	C.wasi_config_inherit_stdout(c.ptr())
 	runtime.KeepAlive(c)
+	c.stdout = wasiStdio{inherit: true}
 }
 
 func (c *WasiConfig) SetStderrFile(path string) error {
@@ -140,6 +262,7 @@ func (c *WasiConfig) SetStderrFile(path string) error {
 	runtime.KeepAlive(c)
 	C.free(unsafe.Pointer(pathC))
 	if ok {
+		c.stderr = wasiStdio{path: path}
 		return nil
 	}
 
@@ -149,6 +272,7 @@ func (c *WasiConfig) SetStderrFile(path string) error {
 func (c *WasiConfig) InheritStderr() {
 	C.wasi_config_inherit_stderr(c.ptr())
 	runtime.KeepAlive(c)
+	c.stderr = wasiStdio{inherit: true}
 }
 
 type WasiDirPerms uint8
@@ -170,8 +294,56 @@ func (c *WasiConfig) PreopenDir(path, guestPath string, dirPerms WasiDirPerms, f
 	C.free(unsafe.Pointer(pathC))
 	C.free(unsafe.Pointer(guestPathC))
 	if ok {
+		c.preopens = append(c.preopens, wasiPreopen{path, guestPath, dirPerms, filePerms})
 		return nil
 	}
 
 	return errors.New("failed to preopen directory")
 }
+
+// Clone creates a new `WasiConfig` with the same configuration as this one.
+//
+// Since `Store.SetWasi` consumes the configuration it's given, this can be
+// used to treat a `WasiConfig` as a template which is configured once and then
+// cloned for each new `Store`. Arguments, environment variables, standard
+// streams and preopened directories are all carried over to the clone. Note
+// that files configured for the standard streams, and preopened directories,
+// are opened again for the clone, which may fail and return an error.
+//
+// A configuration can be cloned even after it has been consumed by
+// `Store.SetWasi`.
+func (c *WasiConfig) Clone() (*WasiConfig, error) {
+	ret := NewWasiConfig()
+	if len(c.argv) > 0 {
+		ret.SetArgv(c.argv)
+	}
+	if len(c.envKeys) > 0 {
+		ret.SetEnv(c.envKeys, c.envValues)
+	}
+	streams := []struct {
+		stdio   wasiStdio
+		inherit func()
+		setFile func(string) error
+	}{
+		{c.stdin, ret.InheritStdin, ret.SetStdinFile},
+		{c.stdout, ret.InheritStdout, ret.SetStdoutFile},
+		{c.stderr, ret.InheritStderr, ret.SetStderrFile},
+	}
+	for _, stream := range streams {
+		if stream.stdio.inherit {
+			stream.inherit()
+		} else if stream.stdio.path != "" {
+			if err := stream.setFile(stream.stdio.path); err != nil {
+				ret.Close()
+				return nil, err
+			}
+		}
+	}
+	for _, p := range c.preopens {
+		if err := ret.PreopenDir(p.path, p.guestPath, p.dirPerms, p.filePerms); err != nil {
+			ret.Close()
+			return nil, err
+		}
+	}
+	return ret, nil
+}
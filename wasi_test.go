@@ -1,7 +1,15 @@
 package wasmtime
 
-import "testing"
-import "github.com/stretchr/testify/require"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 func TestWasiConfig(t *testing.T) {
 	config := NewWasiConfig()
@@ -13,3 +21,231 @@ func TestWasiConfig(t *testing.T) {
 	require.Nil(t, err)
 
 }
+
+func newWasiExitModule(t *testing.T, engine *Engine, code int) *Module {
+	wasm, err := Wat2Wasm(fmt.Sprintf(`
+	  (module
+	    (import "wasi_snapshot_preview1" "proc_exit" (func $exit (param i32)))
+	    (func (export "_start")
+	      i32.const %d
+	      call $exit)
+	  )
+	`, code))
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	return module
+}
+
+func newWasiLinker(t *testing.T, engine *Engine) *Linker {
+	linker := NewLinker(engine)
+	require.NoError(t, linker.DefineWasi())
+	return linker
+}
+
+func TestWasiExitError(t *testing.T) {
+	engine := NewEngine()
+	module := newWasiExitModule(t, engine, 3)
+	store := NewStore(engine)
+	store.SetWasi(NewWasiConfig())
+	linker := newWasiLinker(t, engine)
+
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	_, err = instance.GetFunc(store, "_start").Call(store)
+	require.Error(t, err)
+
+	var exit *ExitError
+	require.True(t, errors.As(err, &exit))
+	require.Equal(t, int32(3), exit.ExitCode())
+
+	wasmErr, ok := err.(*Error)
+	require.True(t, ok)
+	status, ok := wasmErr.ExitStatus()
+	require.True(t, ok)
+	require.Equal(t, int32(3), status)
+}
+
+func TestWasiExitErrorInstantiate(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (import "wasi_snapshot_preview1" "proc_exit" (func $exit (param i32)))
+	    (func $start
+	      i32.const 2
+	      call $exit)
+	    (start $start)
+	  )
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	store := NewStore(engine)
+	store.SetWasi(NewWasiConfig())
+
+	_, err = newWasiLinker(t, engine).Instantiate(store, module)
+	var exit *ExitError
+	require.True(t, errors.As(err, &exit))
+	require.Equal(t, int32(2), exit.ExitCode())
+}
+
+func TestRunWasiCommand(t *testing.T) {
+	engine := NewEngine()
+
+	store := NewStore(engine)
+	store.SetWasi(NewWasiConfig())
+	code, err := RunWasiCommand(store, newWasiLinker(t, engine), newWasiExitModule(t, engine, 0))
+	require.NoError(t, err)
+	require.Equal(t, 0, code)
+
+	store = NewStore(engine)
+	store.SetWasi(NewWasiConfig())
+	linker := newWasiLinker(t, engine)
+	code, err = RunWasiCommand(store, linker, newWasiExitModule(t, engine, 5))
+	require.Error(t, err)
+	require.Equal(t, 5, code)
+	var exit *ExitError
+	require.True(t, errors.As(err, &exit))
+
+	wasm, err := Wat2Wasm(`(module (func (export "_start") unreachable))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	store = NewStore(engine)
+	store.SetWasi(NewWasiConfig())
+	code, err = RunWasiCommand(store, linker, module)
+	require.Error(t, err)
+	require.Equal(t, -1, code)
+
+	// The linker is left unmodified, so it can be reused for other commands.
+	require.False(t, linker.Has("", "_start"))
+}
+
+func TestWasiConfigArgv(t *testing.T) {
+	config := NewWasiConfig()
+	defer config.Close()
+	require.Empty(t, config.Argv())
+
+	config.SetArgv([]string{"prog"})
+	config.AppendArgs("-v", "file")
+	require.Equal(t, []string{"prog", "-v", "file"}, config.Argv())
+
+	config.SetArgv([]string{"other"})
+	require.Equal(t, []string{"other"}, config.Argv())
+
+	config.InheritArgv()
+	require.Equal(t, os.Args, config.Argv())
+}
+
+func TestWasiConfigEnv(t *testing.T) {
+	t.Setenv("WASMTIME_GO_PUBLIC", "yes")
+	t.Setenv("WASMTIME_GO_SECRET", "hunter2")
+
+	config := NewWasiConfig()
+	defer config.Close()
+	config.AddEnv("TENANT", "a")
+	config.AddEnv("TENANT", "b")
+	config.InheritEnvFiltered(func(key string) bool {
+		return strings.HasPrefix(key, "WASMTIME_GO_") && key != "WASMTIME_GO_SECRET"
+	})
+	keys, values := config.Env()
+	require.Equal(t, []string{"TENANT", "WASMTIME_GO_PUBLIC"}, keys)
+	require.Equal(t, []string{"b", "yes"}, values)
+
+	config.SetEnv([]string{"A"}, []string{"1"})
+	keys, values = config.Env()
+	require.Equal(t, []string{"A"}, keys)
+	require.Equal(t, []string{"1"}, values)
+
+	config.InheritEnv()
+	keys, _ = config.Env()
+	require.Contains(t, keys, "WASMTIME_GO_SECRET")
+}
+
+func TestWasiConfigEnvVisible(t *testing.T) {
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (import "wasi_snapshot_preview1" "environ_sizes_get"
+	      (func $sizes (param i32 i32) (result i32)))
+	    (memory 1)
+	    (func (export "count") (result i32)
+	      (drop (call $sizes (i32.const 0) (i32.const 4)))
+	      (i32.load (i32.const 0)))
+	  )
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	config := NewWasiConfig()
+	config.AddEnv("A", "1")
+	config.AddEnv("B", "2")
+	config.AddEnv("A", "3")
+	store := NewStore(engine)
+	store.SetWasi(config)
+	instance, err := newWasiLinker(t, engine).Instantiate(store, module)
+	require.NoError(t, err)
+	count, err := instance.GetFunc(store, "count").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), count)
+}
+
+func TestWasiConfigClone(t *testing.T) {
+	dir := t.TempDir()
+	stdoutPath := filepath.Join(dir, "stdout")
+
+	template := NewWasiConfig()
+	template.SetArgv([]string{"prog", "arg"})
+	template.AddEnv("KEY", "value")
+	require.NoError(t, template.SetStdoutFile(stdoutPath))
+	require.NoError(t, template.PreopenDir(dir, "/", DIR_READ, FILE_READ))
+
+	engine := NewEngine()
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (import "wasi_snapshot_preview1" "fd_write"
+	      (func $fd_write (param i32 i32 i32 i32) (result i32)))
+	    (memory 1)
+	    (data (i32.const 8) "hi\n")
+	    (func (export "_start")
+	      (i32.store (i32.const 0) (i32.const 8))
+	      (i32.store (i32.const 4) (i32.const 3))
+	      (drop (call $fd_write (i32.const 1) (i32.const 0) (i32.const 1) (i32.const 20))))
+	  )
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := newWasiLinker(t, engine)
+
+	for i := 0; i < 2; i++ {
+		config, err := template.Clone()
+		require.NoError(t, err)
+		require.Equal(t, template.Argv(), config.Argv())
+		keys, values := config.Env()
+		require.Equal(t, []string{"KEY"}, keys)
+		require.Equal(t, []string{"value"}, values)
+
+		store := NewStore(engine)
+		store.SetWasi(config)
+		code, err := RunWasiCommand(store, linker, module)
+		require.NoError(t, err)
+		require.Equal(t, 0, code)
+
+		out, err := os.ReadFile(stdoutPath)
+		require.NoError(t, err)
+		require.Equal(t, "hi\n", string(out))
+	}
+
+	// The template itself can still be cloned after being consumed.
+	NewStore(engine).SetWasi(template)
+	_, err = template.Clone()
+	require.NoError(t, err)
+
+	bad := NewWasiConfig()
+	require.NoError(t, bad.PreopenDir(dir, "/", DIR_READ, FILE_READ))
+	require.NoError(t, os.RemoveAll(dir))
+	_, err = bad.Clone()
+	require.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package wasm2wat
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errWasmEOF = errors.New("unexpected end of wasm binary")
+
+// A minimal reader of the wasm binary format.
+type wasmReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *wasmReader) eof() bool {
+	return r.pos >= len(r.buf)
+}
+
+func (r *wasmReader) byte() (byte, error) {
+	if r.eof() {
+		return 0, errWasmEOF
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *wasmReader) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(r.buf)-r.pos {
+		return nil, errWasmEOF
+	}
+	ret := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return ret, nil
+}
+
+// Reads an unsigned LEB128-encoded 32-bit integer.
+func (r *wasmReader) u32() (uint32, error) {
+	ret, err := r.leb(32, false)
+	return uint32(ret), err
+}
+
+// Reads an unsigned LEB128-encoded 64-bit integer.
+func (r *wasmReader) u64() (uint64, error) {
+	return r.leb(64, false)
+}
+
+// Reads a signed LEB128-encoded 32-bit integer.
+func (r *wasmReader) s32() (int32, error) {
+	ret, err := r.leb(32, true)
+	return int32(ret), err
+}
+
+// Reads a signed LEB128-encoded 64-bit integer.
+func (r *wasmReader) s64() (int64, error) {
+	ret, err := r.leb(64, true)
+	return int64(ret), err
+}
+
+// Reads a LEB128-encoded integer of at most `bits` bits, sign-extending it if
+// `signed` is set.
+func (r *wasmReader) leb(bits int, signed bool) (uint64, error) {
+	var ret uint64
+	for shift := 0; shift < bits; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		ret |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			if signed && shift+7 < 64 && b&0x40 != 0 {
+				ret |= ^uint64(0) << (shift + 7)
+			}
+			return ret, nil
+		}
+	}
+	return 0, errors.New("invalid LEB128 integer in wasm binary")
+}
+
+// Reads a little-endian fixed-width 32-bit integer.
+func (r *wasmReader) fixed32() (uint32, error) {
+	b, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// Reads a little-endian fixed-width 64-bit integer.
+func (r *wasmReader) fixed64() (uint64, error) {
+	b, err := r.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// Reads a length-prefixed UTF-8 name.
+func (r *wasmReader) name() (string, error) {
+	n, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,1413 @@
+// Package wasm2wat prints WebAssembly binaries in the text format, the inverse
+// of `wasmtime.Wat2Wasm`, for example to diff generated modules in review or
+// to snapshot test code generators:
+//
+//	text, err := wasm2wat.Print(wasm)
+//
+// This is a pure Go printer kept apart from the wasmtime package, as the
+// Wasmtime C API doesn't expose one. Its scope is limited to the WebAssembly
+// MVP along with the bulk memory, reference types, sign extension, saturating
+// float-to-int conversion, multi-value, multi-memory, memory64 and tail call
+// proposals, and an error is returned for binaries using anything else, such
+// as SIMD, GC, threads or exceptions.
+package wasm2wat
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Options configures how `PrintWithOptions` prints a module.
+type Options struct {
+	// FoldExprs prints instructions as folded S-expressions, such as
+	// `(i32.add (local.get 0) (i32.const 1))`, where possible.
+	FoldExprs bool
+	// IgnoreNames ignores the `name` custom section of the module, so that
+	// functions and locals are always referred to by their index rather than
+	// by the identifiers found there.
+	IgnoreNames bool
+}
+
+// Print converts the binary format of WebAssembly to the text format.
+//
+// This is the same as calling `PrintWithOptions` with the default options.
+func Print(wasm []byte) (string, error) {
+	return PrintWithOptions(wasm, Options{})
+}
+
+// PrintWithOptions converts the binary format of WebAssembly to the text
+// format, configured by `opts`.
+//
+// An error is returned for malformed binaries and those using proposals
+// outside the scope of this package.
+func PrintWithOptions(wasm []byte, opts Options) (string, error) {
+	p := &watPrinter{opts: opts}
+	if err := p.module(wasm); err != nil {
+		return "", err
+	}
+	return p.out.String(), nil
+}
+
+type watFuncType struct {
+	params, results []string
+}
+
+// State of printing a module to the text format.
+type watPrinter struct {
+	opts Options
+	out  strings.Builder
+
+	types     []watFuncType
+	funcTypes []uint32 // the type of every function, including imports
+	imported  int      // number of imported functions
+
+	// number of items of each kind seen so far, for printing their indices
+	tables, memories, globals, elems, datas, codes int
+
+	// identifiers from the `name` section
+	moduleName string
+	funcNames  map[uint32]string
+	localNames map[uint32]map[uint32]string
+}
+
+type watSection struct {
+	id       byte
+	contents []byte
+}
+
+func (p *watPrinter) module(wasm []byte) error {
+	r := &wasmReader{buf: wasm}
+	magic, err := r.bytes(4)
+	if err != nil || string(magic) != "\x00asm" {
+		return errors.New("not a WebAssembly binary")
+	}
+	version, err := r.fixed32()
+	if err != nil {
+		return err
+	}
+	if version != 1 {
+		return fmt.Errorf("unsupported WebAssembly binary version 0x%x", version)
+	}
+
+	// Sections are collected first as the `name` section, which comes last,
+	// is needed before printing anything.
+	var sections []watSection
+	for !r.eof() {
+		id, err := r.byte()
+		if err != nil {
+			return err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return err
+		}
+		contents, err := r.bytes(int(size))
+		if err != nil {
+			return err
+		}
+		sections = append(sections, watSection{id, contents})
+		if id == 0 && !p.opts.IgnoreNames {
+			section := &wasmReader{buf: contents}
+			if name, err := section.name(); err == nil && name == "name" {
+				// The name section is only informational, so it's ignored
+				// entirely if it's malformed.
+				if err := p.names(section); err != nil {
+					p.moduleName, p.funcNames, p.localNames = "", nil, nil
+				}
+			}
+		}
+	}
+
+	p.out.WriteString("(module")
+	if p.moduleName != "" {
+		p.out.WriteString(" $" + p.moduleName)
+	}
+	p.out.WriteString("\n")
+	var funcs []uint32
+	for _, section := range sections {
+		r := &wasmReader{buf: section.contents}
+		var err error
+		switch section.id {
+		case 0, 12:
+			// custom sections and the data count section aren't printed
+		case 1:
+			err = p.typeSection(r)
+		case 2:
+			err = p.importSection(r)
+		case 3:
+			funcs, err = watVec(r, func() (uint32, error) { return r.u32() })
+			p.funcTypes = append(p.funcTypes, funcs...)
+		case 4:
+			err = p.tableSection(r)
+		case 5:
+			err = p.memorySection(r)
+		case 6:
+			err = p.globalSection(r)
+		case 7:
+			err = p.exportSection(r)
+		case 8:
+			var idx uint32
+			if idx, err = r.u32(); err == nil {
+				fmt.Fprintf(&p.out, "  (start %s)\n", p.funcRef(idx))
+			}
+		case 9:
+			err = p.elemSection(r)
+		case 10:
+			err = p.codeSection(r)
+		case 11:
+			err = p.dataSection(r)
+		default:
+			err = fmt.Errorf("unsupported section %d", section.id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	p.out.WriteString(")\n")
+	return nil
+}
+
+// Reads the function and local names of the `name` custom section, discarding
+// names which aren't valid or unique identifiers.
+func (p *watPrinter) names(r *wasmReader) error {
+	p.funcNames = make(map[uint32]string)
+	p.localNames = make(map[uint32]map[uint32]string)
+	for !r.eof() {
+		id, err := r.byte()
+		if err != nil {
+			return err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return err
+		}
+		contents, err := r.bytes(int(size))
+		if err != nil {
+			return err
+		}
+		sub := &wasmReader{buf: contents}
+		switch id {
+		case 0:
+			name, err := sub.name()
+			if err != nil {
+				return err
+			}
+			if watValidID(name) {
+				p.moduleName = name
+			}
+		case 1:
+			if p.funcNames, err = watNameMap(sub); err != nil {
+				return err
+			}
+		case 2:
+			count, err := sub.u32()
+			if err != nil {
+				return err
+			}
+			for i := uint32(0); i < count; i++ {
+				idx, err := sub.u32()
+				if err != nil {
+					return err
+				}
+				if p.localNames[idx], err = watNameMap(sub); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func watNameMap(r *wasmReader) (map[uint32]string, error) {
+	count, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[uint32]string)
+	seen := make(map[string]bool)
+	for i := uint32(0); i < count; i++ {
+		idx, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		name, err := r.name()
+		if err != nil {
+			return nil, err
+		}
+		if !watValidID(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		ret[idx] = name
+	}
+	return ret, nil
+}
+
+func watValidID(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c <= ' ' || c > '~' || strings.ContainsRune("\"(),;[]{}", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func watVec[T any](r *wasmReader, f func() (T, error)) ([]T, error) {
+	count, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	var ret []T
+	for i := uint32(0); i < count; i++ {
+		item, err := f()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, item)
+	}
+	return ret, nil
+}
+
+func watValType(r *wasmReader) (string, error) {
+	b, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+	switch b {
+	case 0x7f:
+		return "i32", nil
+	case 0x7e:
+		return "i64", nil
+	case 0x7d:
+		return "f32", nil
+	case 0x7c:
+		return "f64", nil
+	case 0x7b:
+		return "v128", nil
+	case 0x70:
+		return "funcref", nil
+	case 0x6f:
+		return "externref", nil
+	}
+	return "", fmt.Errorf("unsupported value type 0x%x", b)
+}
+
+func watHeapType(r *wasmReader) (string, error) {
+	b, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+	switch b {
+	case 0x70:
+		return "func", nil
+	case 0x6f:
+		return "extern", nil
+	}
+	return "", fmt.Errorf("unsupported heap type 0x%x", b)
+}
+
+func (p *watPrinter) typeSection(r *wasmReader) error {
+	types, err := watVec(r, func() (watFuncType, error) {
+		form, err := r.byte()
+		if err != nil {
+			return watFuncType{}, err
+		}
+		if form != 0x60 {
+			return watFuncType{}, fmt.Errorf("unsupported type form 0x%x", form)
+		}
+		params, err := watVec(r, func() (string, error) { return watValType(r) })
+		if err != nil {
+			return watFuncType{}, err
+		}
+		results, err := watVec(r, func() (string, error) { return watValType(r) })
+		return watFuncType{params, results}, err
+	})
+	if err != nil {
+		return err
+	}
+	for _, ty := range types {
+		fmt.Fprintf(&p.out, "  (type (;%d;) (func%s))\n", len(p.types), watSignature(ty, nil))
+		p.types = append(p.types, ty)
+	}
+	return nil
+}
+
+// Formats the params and results of `ty`, naming params with `names`.
+func watSignature(ty watFuncType, names map[uint32]string) string {
+	var b strings.Builder
+	named := false
+	for i := range ty.params {
+		if _, ok := names[uint32(i)]; ok {
+			named = true
+		}
+	}
+	if named {
+		for i, param := range ty.params {
+			if name, ok := names[uint32(i)]; ok {
+				fmt.Fprintf(&b, " (param $%s %s)", name, param)
+			} else {
+				fmt.Fprintf(&b, " (param %s)", param)
+			}
+		}
+	} else if len(ty.params) > 0 {
+		b.WriteString(" (param " + strings.Join(ty.params, " ") + ")")
+	}
+	if len(ty.results) > 0 {
+		b.WriteString(" (result " + strings.Join(ty.results, " ") + ")")
+	}
+	return b.String()
+}
+
+func (p *watPrinter) funcType(idx uint32) (watFuncType, error) {
+	if int(idx) >= len(p.types) {
+		return watFuncType{}, fmt.Errorf("type index %d out of bounds", idx)
+	}
+	return p.types[idx], nil
+}
+
+func (p *watPrinter) funcRef(idx uint32) string {
+	if name, ok := p.funcNames[idx]; ok {
+		return "$" + name
+	}
+	return strconv.FormatUint(uint64(idx), 10)
+}
+
+// Formats the declaration of function `idx`, such as `$f (;1;)`.
+func (p *watPrinter) funcDecl(idx uint32) string {
+	if name, ok := p.funcNames[idx]; ok {
+		return fmt.Sprintf("$%s (;%d;)", name, idx)
+	}
+	return fmt.Sprintf("(;%d;)", idx)
+}
+
+func watLimits(r *wasmReader) (string, error) {
+	flags, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+	if flags&^0x7 != 0 {
+		return "", fmt.Errorf("unsupported limits flags 0x%x", flags)
+	}
+	var b strings.Builder
+	if flags&0x4 != 0 {
+		b.WriteString("i64 ")
+	}
+	min, err := r.u64()
+	if err != nil {
+		return "", err
+	}
+	b.WriteString(strconv.FormatUint(min, 10))
+	if flags&0x1 != 0 {
+		max, err := r.u64()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(" " + strconv.FormatUint(max, 10))
+	}
+	if flags&0x2 != 0 {
+		b.WriteString(" shared")
+	}
+	return b.String(), nil
+}
+
+func watTableType(r *wasmReader) (string, error) {
+	ty, err := watValType(r)
+	if err != nil {
+		return "", err
+	}
+	limits, err := watLimits(r)
+	if err != nil {
+		return "", err
+	}
+	return limits + " " + ty, nil
+}
+
+func watGlobalType(r *wasmReader) (string, error) {
+	ty, err := watValType(r)
+	if err != nil {
+		return "", err
+	}
+	mut, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+	if mut == 1 {
+		return "(mut " + ty + ")", nil
+	}
+	return ty, nil
+}
+
+func (p *watPrinter) importSection(r *wasmReader) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < count; i++ {
+		module, err := r.name()
+		if err != nil {
+			return err
+		}
+		name, err := r.name()
+		if err != nil {
+			return err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+		var desc string
+		switch kind {
+		case 0:
+			ty, err := r.u32()
+			if err != nil {
+				return err
+			}
+			idx := uint32(len(p.funcTypes))
+			p.funcTypes = append(p.funcTypes, ty)
+			p.imported++
+			desc = fmt.Sprintf("(func %s (type %d))", p.funcDecl(idx), ty)
+		case 1:
+			ty, err := watTableType(r)
+			if err != nil {
+				return err
+			}
+			desc = fmt.Sprintf("(table (;%d;) %s)", p.tables, ty)
+			p.tables++
+		case 2:
+			limits, err := watLimits(r)
+			if err != nil {
+				return err
+			}
+			desc = fmt.Sprintf("(memory (;%d;) %s)", p.memories, limits)
+			p.memories++
+		case 3:
+			ty, err := watGlobalType(r)
+			if err != nil {
+				return err
+			}
+			desc = fmt.Sprintf("(global (;%d;) %s)", p.globals, ty)
+			p.globals++
+		default:
+			return fmt.Errorf("unsupported import kind %d", kind)
+		}
+		fmt.Fprintf(&p.out, "  (import %s %s %s)\n", watString([]byte(module)), watString([]byte(name)), desc)
+	}
+	return nil
+}
+
+func (p *watPrinter) tableSection(r *wasmReader) error {
+	tables, err := watVec(r, func() (string, error) { return watTableType(r) })
+	if err != nil {
+		return err
+	}
+	for _, ty := range tables {
+		fmt.Fprintf(&p.out, "  (table (;%d;) %s)\n", p.tables, ty)
+		p.tables++
+	}
+	return nil
+}
+
+func (p *watPrinter) memorySection(r *wasmReader) error {
+	memories, err := watVec(r, func() (string, error) { return watLimits(r) })
+	if err != nil {
+		return err
+	}
+	for _, limits := range memories {
+		fmt.Fprintf(&p.out, "  (memory (;%d;) %s)\n", p.memories, limits)
+		p.memories++
+	}
+	return nil
+}
+
+func (p *watPrinter) globalSection(r *wasmReader) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < count; i++ {
+		ty, err := watGlobalType(r)
+		if err != nil {
+			return err
+		}
+		init, err := p.constExpr(r)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&p.out, "  (global (;%d;) %s %s)\n", p.globals, ty, init)
+		p.globals++
+	}
+	return nil
+}
+
+func (p *watPrinter) exportSection(r *wasmReader) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < count; i++ {
+		name, err := r.name()
+		if err != nil {
+			return err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+		idx, err := r.u32()
+		if err != nil {
+			return err
+		}
+		var desc string
+		switch kind {
+		case 0:
+			desc = "func " + p.funcRef(idx)
+		case 1:
+			desc = fmt.Sprintf("table %d", idx)
+		case 2:
+			desc = fmt.Sprintf("memory %d", idx)
+		case 3:
+			desc = fmt.Sprintf("global %d", idx)
+		default:
+			return fmt.Errorf("unsupported export kind %d", kind)
+		}
+		fmt.Fprintf(&p.out, "  (export %s (%s))\n", watString([]byte(name)), desc)
+	}
+	return nil
+}
+
+// Reads a constant expression, formatting it as a folded offset expression
+// such as `(i32.const 0)`.
+func (p *watPrinter) constExpr(r *wasmReader) (string, error) {
+	instrs, err := p.constInstrs(r)
+	if err != nil {
+		return "", err
+	}
+	if len(instrs) == 1 {
+		return instrs[0], nil
+	}
+	return "(offset " + strings.Join(instrs, " ") + ")", nil
+}
+
+// Reads a constant expression, returning each of its instructions as a plain
+// instruction such as `(i32.const 0)`.
+func (p *watPrinter) constInstrs(r *wasmReader) ([]string, error) {
+	instrs, err := p.instrs(r, &watFuncContext{}, false)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, len(instrs))
+	for i, instr := range instrs {
+		ret[i] = "(" + instr.text + ")"
+	}
+	return ret, nil
+}
+
+func (p *watPrinter) elemSection(r *wasmReader) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < count; i++ {
+		flags, err := r.u32()
+		if err != nil {
+			return err
+		}
+		if flags > 7 {
+			return fmt.Errorf("unsupported element segment flags %d", flags)
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "  (elem (;%d;)", p.elems)
+		p.elems++
+		if flags&0x1 == 0 {
+			// active segment
+			if flags&0x2 != 0 {
+				table, err := r.u32()
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(&b, " (table %d)", table)
+			}
+			offset, err := p.constExpr(r)
+			if err != nil {
+				return err
+			}
+			b.WriteString(" " + offset)
+		} else if flags&0x2 != 0 {
+			b.WriteString(" declare")
+		}
+		if flags&0x4 == 0 {
+			// function indices
+			if flags&0x3 != 0 {
+				if kind, err := r.byte(); err != nil {
+					return err
+				} else if kind != 0 {
+					return fmt.Errorf("unsupported element kind %d", kind)
+				}
+			}
+			funcs, err := watVec(r, func() (uint32, error) { return r.u32() })
+			if err != nil {
+				return err
+			}
+			b.WriteString(" func")
+			for _, f := range funcs {
+				b.WriteString(" " + p.funcRef(f))
+			}
+		} else {
+			// expressions
+			ty := "funcref"
+			if flags&0x3 != 0 {
+				if ty, err = watValType(r); err != nil {
+					return err
+				}
+			}
+			b.WriteString(" " + ty)
+			items, err := watVec(r, func() ([]string, error) { return p.constInstrs(r) })
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				b.WriteString(" (item " + strings.Join(item, " ") + ")")
+			}
+		}
+		b.WriteString(")\n")
+		p.out.WriteString(b.String())
+	}
+	return nil
+}
+
+func (p *watPrinter) dataSection(r *wasmReader) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < count; i++ {
+		flags, err := r.u32()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&p.out, "  (data (;%d;)", p.datas)
+		p.datas++
+		switch flags {
+		case 0, 2:
+			if flags == 2 {
+				mem, err := r.u32()
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(&p.out, " (memory %d)", mem)
+			}
+			offset, err := p.constExpr(r)
+			if err != nil {
+				return err
+			}
+			p.out.WriteString(" " + offset)
+		case 1:
+		default:
+			return fmt.Errorf("unsupported data segment flags %d", flags)
+		}
+		size, err := r.u32()
+		if err != nil {
+			return err
+		}
+		data, err := r.bytes(int(size))
+		if err != nil {
+			return err
+		}
+		p.out.WriteString(" " + watString(data) + ")\n")
+	}
+	return nil
+}
+
+func watString(b []byte) string {
+	var s strings.Builder
+	s.WriteByte('"')
+	for _, c := range b {
+		if c >= 0x20 && c < 0x7f && c != '"' && c != '\\' {
+			s.WriteByte(c)
+		} else {
+			fmt.Fprintf(&s, "\\%02x", c)
+		}
+	}
+	s.WriteByte('"')
+	return s.String()
+}
+
+func (p *watPrinter) codeSection(r *wasmReader) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < count; i++ {
+		size, err := r.u32()
+		if err != nil {
+			return err
+		}
+		body, err := r.bytes(int(size))
+		if err != nil {
+			return err
+		}
+		idx := uint32(p.imported + p.codes)
+		p.codes++
+		if int(idx) >= len(p.funcTypes) {
+			return errors.New("function and code section have inconsistent lengths")
+		}
+		if err := p.function(idx, &wasmReader{buf: body}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Context of the function whose instructions are being read, used to determine
+// the number of operands and results of instructions.
+type watFuncContext struct {
+	results int
+	locals  map[uint32]string
+	// the number of values branches to each enclosing label take, innermost
+	// last
+	labels []int
+}
+
+func (p *watPrinter) function(idx uint32, r *wasmReader) error {
+	typeIdx := p.funcTypes[idx]
+	ty, err := p.funcType(typeIdx)
+	if err != nil {
+		return err
+	}
+	names := p.localNames[idx]
+	fmt.Fprintf(&p.out, "  (func %s (type %d)%s\n", p.funcDecl(idx), typeIdx, watSignature(ty, names))
+
+	groups, err := r.u32()
+	if err != nil {
+		return err
+	}
+	var locals []string
+	for i := uint32(0); i < groups; i++ {
+		n, err := r.u32()
+		if err != nil {
+			return err
+		}
+		ty, err := watValType(r)
+		if err != nil {
+			return err
+		}
+		if uint64(len(locals))+uint64(n) > 50000 {
+			return errors.New("too many locals")
+		}
+		for j := uint32(0); j < n; j++ {
+			locals = append(locals, ty)
+		}
+	}
+	if len(locals) > 0 {
+		named := false
+		for i := range locals {
+			if _, ok := names[uint32(len(ty.params)+i)]; ok {
+				named = true
+			}
+		}
+		if named {
+			for i, local := range locals {
+				if name, ok := names[uint32(len(ty.params)+i)]; ok {
+					fmt.Fprintf(&p.out, "    (local $%s %s)\n", name, local)
+				} else {
+					fmt.Fprintf(&p.out, "    (local %s)\n", local)
+				}
+			}
+		} else {
+			fmt.Fprintf(&p.out, "    (local %s)\n", strings.Join(locals, " "))
+		}
+	}
+
+	ctx := &watFuncContext{
+		results: len(ty.results),
+		locals:  names,
+		labels:  []int{len(ty.results)},
+	}
+	instrs, err := p.instrs(r, ctx, false)
+	if err != nil {
+		return err
+	}
+	if !r.eof() {
+		return errors.New("trailing bytes at the end of a function body")
+	}
+	if p.opts.FoldExprs {
+		for _, node := range watFold(instrs) {
+			node.print(&p.out, 2)
+		}
+	} else {
+		for _, instr := range instrs {
+			instr.print(&p.out, 2)
+		}
+	}
+	p.out.WriteString("  )\n")
+	return nil
+}
+
+// An instruction which has been read from a function body.
+type watInstr struct {
+	// mnemonic and immediates, such as `i32.const 1`
+	text string
+	// the number of operands and results, where -1 operands means that the
+	// instruction can't be folded
+	pops, pushes int
+
+	// for `block`, `loop` and `if`, the instructions within the block
+	block   bool
+	isIf    bool
+	body    []*watInstr
+	els     []*watInstr
+	hasElse bool
+}
+
+func (in *watInstr) print(out *strings.Builder, indent int) {
+	prefix := strings.Repeat("  ", indent)
+	out.WriteString(prefix + in.text + "\n")
+	if !in.block {
+		return
+	}
+	for _, child := range in.body {
+		child.print(out, indent+1)
+	}
+	if in.hasElse {
+		out.WriteString(prefix + "else\n")
+		for _, child := range in.els {
+			child.print(out, indent+1)
+		}
+	}
+	out.WriteString(prefix + "end\n")
+}
+
+// An instruction in folded form, along with the instructions producing its
+// operands.
+type watNode struct {
+	instr    *watInstr
+	children []*watNode
+}
+
+// Folds `instrs` into S-expressions. Only the instructions immediately
+// preceding an instruction are folded into it, and only if they each produce a
+// single value, so the order of instructions is always preserved.
+func watFold(instrs []*watInstr) []*watNode {
+	var nodes []*watNode
+	for _, instr := range instrs {
+		node := &watNode{instr: instr}
+		pops := instr.pops
+		if instr.isIf {
+			pops = 1 // only the condition can be folded
+		}
+		if pops > 0 && len(nodes) >= pops {
+			operands := nodes[len(nodes)-pops:]
+			foldable := true
+			for _, operand := range operands {
+				if operand.instr.pushes != 1 {
+					foldable = false
+				}
+			}
+			if foldable {
+				node.children = append([]*watNode(nil), operands...)
+				nodes = nodes[:len(nodes)-pops]
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func (n *watNode) print(out *strings.Builder, indent int) {
+	prefix := strings.Repeat("  ", indent)
+	in := n.instr
+	if !in.block && len(n.children) == 0 {
+		out.WriteString(prefix + "(" + in.text + ")\n")
+		return
+	}
+	out.WriteString(prefix + "(" + in.text + "\n")
+	for _, child := range n.children {
+		child.print(out, indent+1)
+	}
+	if in.isIf {
+		out.WriteString(prefix + "  (then\n")
+		for _, child := range watFold(in.body) {
+			child.print(out, indent+2)
+		}
+		out.WriteString(prefix + "  )\n")
+		if in.hasElse {
+			out.WriteString(prefix + "  (else\n")
+			for _, child := range watFold(in.els) {
+				child.print(out, indent+2)
+			}
+			out.WriteString(prefix + "  )\n")
+		}
+	} else if in.block {
+		for _, child := range watFold(in.body) {
+			child.print(out, indent+1)
+		}
+	}
+	out.WriteString(prefix + ")\n")
+}
+
+// Reads instructions up to and including the `end` terminating them.
+func (p *watPrinter) instrs(r *wasmReader, ctx *watFuncContext, inIf bool) ([]*watInstr, error) {
+	instrs, _, err := p.instrsUntil(r, ctx, inIf)
+	return instrs, err
+}
+
+// Reads instructions up to and including the `end` terminating them, or an
+// `else` if `inIf` is set. The returned bool indicates whether `else` was
+// found.
+func (p *watPrinter) instrsUntil(r *wasmReader, ctx *watFuncContext, inIf bool) ([]*watInstr, bool, error) {
+	var ret []*watInstr
+	for {
+		op, err := r.byte()
+		if err != nil {
+			return nil, false, err
+		}
+		switch {
+		case op == 0x0b:
+			return ret, false, nil
+		case op == 0x05 && inIf:
+			return ret, true, nil
+		}
+		instr, err := p.instr(r, ctx, op)
+		if err != nil {
+			return nil, false, err
+		}
+		ret = append(ret, instr)
+	}
+}
+
+// Reads a block type, returning its text along with its number of params and
+// results.
+func (p *watPrinter) blockType(r *wasmReader) (string, int, int, error) {
+	b, err := r.byte()
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if b == 0x40 {
+		return "", 0, 0, nil
+	}
+	if b >= 0x40 {
+		r.pos--
+		ty, err := watValType(r)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		return " (result " + ty + ")", 0, 1, nil
+	}
+	r.pos--
+	idx, err := r.u32()
+	if err != nil {
+		return "", 0, 0, err
+	}
+	ty, err := p.funcType(idx)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return fmt.Sprintf(" (type %d)%s", idx, watSignature(ty, nil)), len(ty.params), len(ty.results), nil
+}
+
+func (ctx *watFuncContext) label(depth uint32) int {
+	if int(depth) >= len(ctx.labels) {
+		return -1
+	}
+	return ctx.labels[len(ctx.labels)-1-int(depth)]
+}
+
+func (ctx *watFuncContext) local(idx uint32) string {
+	if name, ok := ctx.locals[idx]; ok {
+		return "$" + name
+	}
+	return strconv.FormatUint(uint64(idx), 10)
+}
+
+// Natural alignments of loads and stores, 0x28 through 0x3e, as powers of two.
+var watMemAlign = [...]uint32{2, 3, 2, 3, 0, 0, 1, 1, 0, 0, 1, 1, 2, 2, 2, 3, 2, 3, 0, 1, 0, 1, 2}
+
+var watMemNames = [...]string{
+	"i32.load", "i64.load", "f32.load", "f64.load",
+	"i32.load8_s", "i32.load8_u", "i32.load16_s", "i32.load16_u",
+	"i64.load8_s", "i64.load8_u", "i64.load16_s", "i64.load16_u", "i64.load32_s", "i64.load32_u",
+	"i32.store", "i64.store", "f32.store", "f64.store",
+	"i32.store8", "i32.store16", "i64.store8", "i64.store16", "i64.store32",
+}
+
+// Mnemonics of the numeric instructions without immediates, 0x45 through
+// 0xc4.
+var watNumericNames = strings.Fields(`
+	i32.eqz i32.eq i32.ne i32.lt_s i32.lt_u i32.gt_s i32.gt_u i32.le_s i32.le_u i32.ge_s i32.ge_u
+	i64.eqz i64.eq i64.ne i64.lt_s i64.lt_u i64.gt_s i64.gt_u i64.le_s i64.le_u i64.ge_s i64.ge_u
+	f32.eq f32.ne f32.lt f32.gt f32.le f32.ge
+	f64.eq f64.ne f64.lt f64.gt f64.le f64.ge
+	i32.clz i32.ctz i32.popcnt i32.add i32.sub i32.mul i32.div_s i32.div_u i32.rem_s i32.rem_u
+	i32.and i32.or i32.xor i32.shl i32.shr_s i32.shr_u i32.rotl i32.rotr
+	i64.clz i64.ctz i64.popcnt i64.add i64.sub i64.mul i64.div_s i64.div_u i64.rem_s i64.rem_u
+	i64.and i64.or i64.xor i64.shl i64.shr_s i64.shr_u i64.rotl i64.rotr
+	f32.abs f32.neg f32.ceil f32.floor f32.trunc f32.nearest f32.sqrt
+	f32.add f32.sub f32.mul f32.div f32.min f32.max f32.copysign
+	f64.abs f64.neg f64.ceil f64.floor f64.trunc f64.nearest f64.sqrt
+	f64.add f64.sub f64.mul f64.div f64.min f64.max f64.copysign
+	i32.wrap_i64 i32.trunc_f32_s i32.trunc_f32_u i32.trunc_f64_s i32.trunc_f64_u
+	i64.extend_i32_s i64.extend_i32_u i64.trunc_f32_s i64.trunc_f32_u i64.trunc_f64_s i64.trunc_f64_u
+	f32.convert_i32_s f32.convert_i32_u f32.convert_i64_s f32.convert_i64_u f32.demote_f64
+	f64.convert_i32_s f64.convert_i32_u f64.convert_i64_s f64.convert_i64_u f64.promote_f32
+	i32.reinterpret_f32 i64.reinterpret_f64 f32.reinterpret_i32 f64.reinterpret_i64
+	i32.extend8_s i32.extend16_s i64.extend8_s i64.extend16_s i64.extend32_s
+`)
+
+// Returns whether the numeric instruction `op` takes two operands, as opposed
+// to one.
+func watNumericBinary(op byte) bool {
+	return (op >= 0x46 && op <= 0x4f) || (op >= 0x51 && op <= 0x66) ||
+		(op >= 0x6a && op <= 0x78) || (op >= 0x7c && op <= 0x8a) ||
+		(op >= 0x92 && op <= 0x98) || (op >= 0xa0 && op <= 0xa6)
+}
+
+var watSatNames = strings.Fields(`
+	i32.trunc_sat_f32_s i32.trunc_sat_f32_u i32.trunc_sat_f64_s i32.trunc_sat_f64_u
+	i64.trunc_sat_f32_s i64.trunc_sat_f32_u i64.trunc_sat_f64_s i64.trunc_sat_f64_u
+`)
+
+func (p *watPrinter) instr(r *wasmReader, ctx *watFuncContext, op byte) (*watInstr, error) {
+	simple := func(text string, pops, pushes int) (*watInstr, error) {
+		return &watInstr{text: text, pops: pops, pushes: pushes}, nil
+	}
+	withIdx := func(text string, ref func(uint32) string, pops, pushes int) (*watInstr, error) {
+		idx, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		return simple(text+" "+ref(idx), pops, pushes)
+	}
+	index := func(idx uint32) string { return strconv.FormatUint(uint64(idx), 10) }
+
+	switch {
+	case op == 0x00:
+		return simple("unreachable", 0, 0)
+	case op == 0x01:
+		return simple("nop", 0, 0)
+	case op >= 0x02 && op <= 0x04:
+		bt, params, results, err := p.blockType(r)
+		if err != nil {
+			return nil, err
+		}
+		instr := &watInstr{text: [...]string{"block", "loop", "if"}[op-0x02] + bt, pushes: results, block: true}
+		// Branches to a loop go to its start, taking its params.
+		labelArity := results
+		if op == 0x03 {
+			labelArity = params
+		}
+		if params > 0 {
+			// Blocks with params aren't folded, as their params can't be
+			// folded into them.
+			instr.pushes = -1
+		}
+		ctx.labels = append(ctx.labels, labelArity)
+		if op == 0x04 {
+			instr.isIf = true
+			instr.pops = 1
+			instr.body, instr.hasElse, err = p.instrsUntil(r, ctx, true)
+			if err == nil && instr.hasElse {
+				instr.els, err = p.instrs(r, ctx, false)
+			}
+		} else {
+			instr.body, err = p.instrs(r, ctx, false)
+		}
+		ctx.labels = ctx.labels[:len(ctx.labels)-1]
+		if err != nil {
+			return nil, err
+		}
+		return instr, nil
+	case op == 0x0c || op == 0x0d:
+		depth, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		arity := ctx.label(depth)
+		text := fmt.Sprintf("br %d", depth)
+		if op == 0x0d {
+			text = fmt.Sprintf("br_if %d", depth)
+			if arity < 0 {
+				return simple(text, -1, -1)
+			}
+			return simple(text, arity+1, arity)
+		}
+		return simple(text, arity, 0)
+	case op == 0x0e:
+		depths, err := watVec(r, func() (uint32, error) { return r.u32() })
+		if err != nil {
+			return nil, err
+		}
+		def, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		text := "br_table"
+		for _, depth := range append(depths, def) {
+			text += fmt.Sprintf(" %d", depth)
+		}
+		arity := ctx.label(def)
+		if arity < 0 {
+			return simple(text, -1, 0)
+		}
+		return simple(text, arity+1, 0)
+	case op == 0x0f:
+		return simple("return", ctx.results, 0)
+	case op == 0x10 || op == 0x12:
+		idx, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		if int(idx) >= len(p.funcTypes) {
+			return nil, fmt.Errorf("function index %d out of bounds", idx)
+		}
+		ty, err := p.funcType(p.funcTypes[idx])
+		if err != nil {
+			return nil, err
+		}
+		if op == 0x12 {
+			return simple("return_call "+p.funcRef(idx), len(ty.params), 0)
+		}
+		return simple("call "+p.funcRef(idx), len(ty.params), len(ty.results))
+	case op == 0x11 || op == 0x13:
+		typeIdx, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		table, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		ty, err := p.funcType(typeIdx)
+		if err != nil {
+			return nil, err
+		}
+		text := "call_indirect"
+		pushes := len(ty.results)
+		if op == 0x13 {
+			text = "return_call_indirect"
+			pushes = 0
+		}
+		if table != 0 {
+			text += fmt.Sprintf(" %d", table)
+		}
+		return simple(fmt.Sprintf("%s (type %d)", text, typeIdx), len(ty.params)+1, pushes)
+	case op == 0x1a:
+		return simple("drop", 1, 0)
+	case op == 0x1b:
+		return simple("select", 3, 1)
+	case op == 0x1c:
+		types, err := watVec(r, func() (string, error) { return watValType(r) })
+		if err != nil {
+			return nil, err
+		}
+		return simple("select (result "+strings.Join(types, " ")+")", 3, len(types))
+	case op == 0x20:
+		return withIdx("local.get", ctx.local, 0, 1)
+	case op == 0x21:
+		return withIdx("local.set", ctx.local, 1, 0)
+	case op == 0x22:
+		return withIdx("local.tee", ctx.local, 1, 1)
+	case op == 0x23:
+		return withIdx("global.get", index, 0, 1)
+	case op == 0x24:
+		return withIdx("global.set", index, 1, 0)
+	case op == 0x25:
+		return withIdx("table.get", index, 1, 1)
+	case op == 0x26:
+		return withIdx("table.set", index, 2, 0)
+	case op >= 0x28 && op <= 0x3e:
+		flags, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		var mem uint32
+		if flags&0x40 != 0 {
+			if mem, err = r.u32(); err != nil {
+				return nil, err
+			}
+			flags &^= 0x40
+		}
+		offset, err := r.u64()
+		if err != nil {
+			return nil, err
+		}
+		text := watMemNames[op-0x28]
+		if mem != 0 {
+			text += fmt.Sprintf(" %d", mem)
+		}
+		if offset != 0 {
+			text += fmt.Sprintf(" offset=%d", offset)
+		}
+		if flags != watMemAlign[op-0x28] {
+			if flags >= 64 {
+				return nil, fmt.Errorf("invalid alignment 2**%d", flags)
+			}
+			text += fmt.Sprintf(" align=%d", uint64(1)<<flags)
+		}
+		if op <= 0x35 {
+			return simple(text, 1, 1)
+		}
+		return simple(text, 2, 0)
+	case op == 0x3f || op == 0x40:
+		mem, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		text := "memory.size"
+		pops := 0
+		if op == 0x40 {
+			text, pops = "memory.grow", 1
+		}
+		if mem != 0 {
+			text += fmt.Sprintf(" %d", mem)
+		}
+		return simple(text, pops, 1)
+	case op == 0x41:
+		val, err := r.s32()
+		if err != nil {
+			return nil, err
+		}
+		return simple(fmt.Sprintf("i32.const %d", val), 0, 1)
+	case op == 0x42:
+		val, err := r.s64()
+		if err != nil {
+			return nil, err
+		}
+		return simple(fmt.Sprintf("i64.const %d", val), 0, 1)
+	case op == 0x43:
+		bits, err := r.fixed32()
+		if err != nil {
+			return nil, err
+		}
+		return simple("f32.const "+watF32(bits), 0, 1)
+	case op == 0x44:
+		bits, err := r.fixed64()
+		if err != nil {
+			return nil, err
+		}
+		return simple("f64.const "+watF64(bits), 0, 1)
+	case op >= 0x45 && op <= 0xc4:
+		if watNumericBinary(op) {
+			return simple(watNumericNames[op-0x45], 2, 1)
+		}
+		return simple(watNumericNames[op-0x45], 1, 1)
+	case op == 0xd0:
+		ty, err := watHeapType(r)
+		if err != nil {
+			return nil, err
+		}
+		return simple("ref.null "+ty, 0, 1)
+	case op == 0xd1:
+		return simple("ref.is_null", 1, 1)
+	case op == 0xd2:
+		return withIdx("ref.func", p.funcRef, 0, 1)
+	case op == 0xfc:
+		return p.instrFC(r)
+	}
+	return nil, fmt.Errorf("unsupported opcode 0x%x", op)
+}
+
+// Reads an instruction with the 0xfc prefix.
+func (p *watPrinter) instrFC(r *wasmReader) (*watInstr, error) {
+	op, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	if op <= 7 {
+		return &watInstr{text: watSatNames[op], pops: 1, pushes: 1}, nil
+	}
+	var imms []uint32
+	immCount := map[uint32]int{8: 2, 9: 1, 10: 2, 11: 1, 12: 2, 13: 1, 14: 2, 15: 1, 16: 1, 17: 1}[op]
+	if immCount == 0 {
+		return nil, fmt.Errorf("unsupported opcode 0xfc %d", op)
+	}
+	for i := 0; i < immCount; i++ {
+		imm, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		imms = append(imms, imm)
+	}
+	format := func(text string, pops, pushes int, imms ...uint32) (*watInstr, error) {
+		for _, imm := range imms {
+			text += fmt.Sprintf(" %d", imm)
+		}
+		return &watInstr{text: text, pops: pops, pushes: pushes}, nil
+	}
+	switch op {
+	case 8:
+		// binary: data index then memory, text: memory then data index
+		if imms[1] != 0 {
+			return format("memory.init", 3, 0, imms[1], imms[0])
+		}
+		return format("memory.init", 3, 0, imms[0])
+	case 9:
+		return format("data.drop", 0, 0, imms[0])
+	case 10:
+		if imms[0] != 0 || imms[1] != 0 {
+			return format("memory.copy", 3, 0, imms...)
+		}
+		return format("memory.copy", 3, 0)
+	case 11:
+		if imms[0] != 0 {
+			return format("memory.fill", 3, 0, imms[0])
+		}
+		return format("memory.fill", 3, 0)
+	case 12:
+		// binary: element index then table, text: table then element index
+		return format("table.init", 3, 0, imms[1], imms[0])
+	case 13:
+		return format("elem.drop", 0, 0, imms[0])
+	case 14:
+		return format("table.copy", 3, 0, imms...)
+	case 15:
+		return format("table.grow", 2, 1, imms[0])
+	case 16:
+		return format("table.size", 0, 1, imms[0])
+	default:
+		return format("table.fill", 3, 0, imms[0])
+	}
+}
+
+func watF32(bits uint32) string {
+	sign := ""
+	if bits>>31 != 0 {
+		sign = "-"
+	}
+	f := math.Float32frombits(bits)
+	switch {
+	case math.IsNaN(float64(f)):
+		payload := bits & 0x7fffff
+		if payload == 0x400000 {
+			return sign + "nan"
+		}
+		return fmt.Sprintf("%snan:0x%x", sign, payload)
+	case math.IsInf(float64(f), 0):
+		return sign + "inf"
+	}
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+func watF64(bits uint64) string {
+	sign := ""
+	if bits>>63 != 0 {
+		sign = "-"
+	}
+	f := math.Float64frombits(bits)
+	switch {
+	case math.IsNaN(f):
+		payload := bits & 0xfffffffffffff
+		if payload == 0x8000000000000 {
+			return sign + "nan"
+		}
+		return fmt.Sprintf("%snan:0x%x", sign, payload)
+	case math.IsInf(f, 0):
+		return sign + "inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
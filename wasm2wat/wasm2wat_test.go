@@ -0,0 +1,125 @@
+package wasm2wat
+
+import (
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go/v44"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintRoundTrip(t *testing.T) {
+	// This module is only encoded and never validated, so its function needn't
+	// type check.
+	wasm, err := wasmtime.Wat2Wasm(`(module
+	  (import "env" "f" (func (param i32) (result i32)))
+	  (import "env" "g" (global (mut i64)))
+	  (type (func (param f32 f64) (result f64)))
+	  (memory 1 2)
+	  (table 2 funcref)
+	  (global f32 (f32.const -1.5))
+	  (global (mut i32) (i32.const -7))
+	  (export "run" (func 1))
+	  (export "memory" (memory 0))
+	  (elem (i32.const 0) func 0 1)
+	  (data (i32.const 8) "hello\00\ff")
+	  (func (param i32 i32) (result i32)
+	    (local i64 f32)
+	    block (result i32)
+	      local.get 0
+	      local.get 1
+	      i32.add
+	      br_if 0
+	      i32.const 3
+	      call 0
+	    end
+	    loop
+	      local.get 0
+	      i32.load8_u offset=4
+	      if
+	        nop
+	      else
+	        br 1
+	      end
+	    end
+	    f64.const nan
+	    f64.const -inf
+	    f64.add
+	    drop
+	    i64.const -9223372036854775808
+	    i64.const 0x7fffffffffffffff
+	    i64.add
+	    local.set 2
+	    i32.const 1
+	    i32.const 2
+	    i32.const 0
+	    call_indirect (param i32) (result i32)
+	    i32.const 4
+	    memory.grow
+	    i32.const 0
+	    i32.const 0
+	    memory.fill
+	    i32.trunc_sat_f32_s (f32.const 0.5)
+	    i32.extend8_s
+	    select
+	  )
+	)`)
+	require.NoError(t, err)
+
+	for _, fold := range []bool{false, true} {
+		text, err := PrintWithOptions(wasm, Options{FoldExprs: fold})
+		require.NoError(t, err)
+		roundTrip, err := wasmtime.Wat2Wasm(text)
+		require.NoError(t, err, text)
+		require.Equal(t, wasm, roundTrip, text)
+	}
+}
+
+func TestPrintFolded(t *testing.T) {
+	wasm, err := wasmtime.Wat2Wasm(`(module
+	  (func (param i32) (result i32)
+	    local.get 0
+	    i32.const 1
+	    i32.add)
+	)`)
+	require.NoError(t, err)
+	text, err := Print(wasm)
+	require.NoError(t, err)
+	require.Contains(t, text, "i32.add\n")
+	text, err = PrintWithOptions(wasm, Options{FoldExprs: true})
+	require.NoError(t, err)
+	require.Contains(t, text, "(i32.add\n      (local.get 0)\n      (i32.const 1)\n    )")
+}
+
+func TestPrintNames(t *testing.T) {
+	wasm, err := wasmtime.Wat2Wasm(`(module $m
+	  (func $foo (param $x i32) (local $y i32)
+	    local.get $x
+	    local.set $y)
+	  (func $bar call $foo)
+	)`)
+	require.NoError(t, err)
+	text, err := Print(wasm)
+	require.NoError(t, err)
+	require.Contains(t, text, "(module $m")
+	require.Contains(t, text, "(func $foo (;0;) (type 0) (param $x i32)")
+	require.Contains(t, text, "(local $y i32)")
+	require.Contains(t, text, "local.set $y")
+	require.Contains(t, text, "call $foo")
+	_, err = wasmtime.Wat2Wasm(text)
+	require.NoError(t, err)
+
+	text, err = PrintWithOptions(wasm, Options{IgnoreNames: true})
+	require.NoError(t, err)
+	require.NotContains(t, text, "$")
+	require.Contains(t, text, "call 0")
+}
+
+func TestPrintInvalid(t *testing.T) {
+	_, err := Print([]byte("not wasm"))
+	require.Error(t, err)
+	_, err = Print([]byte{0, 'a', 's', 'm', 1, 0, 0, 0, 1, 10})
+	require.Error(t, err)
+	// components aren't supported
+	_, err = Print([]byte{0, 'a', 's', 'm', 0x0d, 0, 1, 0})
+	require.Error(t, err)
+}
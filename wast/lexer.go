@@ -0,0 +1,213 @@
+package wast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// An S-expression of a `.wast` script, which is either a list, a string or
+// any other token such as a keyword, number or identifier.
+type sexpr struct {
+	list   []*sexpr
+	isList bool
+	// the decoded contents of a string
+	str   []byte
+	isStr bool
+	atom  string
+
+	// the source text of this expression and the line it starts on
+	src  string
+	line int
+}
+
+// Returns the source text of this expression.
+func (e *sexpr) String() string {
+	return e.src
+}
+
+// Returns the keyword at the head of this list, if any.
+func (e *sexpr) head() string {
+	if !e.isList || len(e.list) == 0 || e.list[0].isList || e.list[0].isStr {
+		return ""
+	}
+	return e.list[0].atom
+}
+
+// Returns whether this is an identifier such as `$m`.
+func (e *sexpr) isID() bool {
+	return !e.isList && !e.isStr && strings.HasPrefix(e.atom, "$")
+}
+
+type parser struct {
+	src  string
+	pos  int
+	line int
+}
+
+// Parses the top-level S-expressions of `src`.
+func parse(src string) ([]*sexpr, error) {
+	p := &parser{src: src, line: 1}
+	var ret []*sexpr
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos == len(p.src) {
+			return ret, nil
+		}
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, e)
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) advance() {
+	if p.src[p.pos] == '\n' {
+		p.line++
+	}
+	p.pos++
+}
+
+// Skips whitespace and comments.
+func (p *parser) skip() error {
+	for p.pos < len(p.src) {
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], ";;"):
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.advance()
+			}
+		case strings.HasPrefix(p.src[p.pos:], "(;"):
+			line := p.line
+			depth := 0
+			for {
+				if p.pos >= len(p.src) {
+					return fmt.Errorf("line %d: unterminated block comment", line)
+				}
+				if strings.HasPrefix(p.src[p.pos:], "(;") {
+					depth++
+					p.pos += 2
+				} else if strings.HasPrefix(p.src[p.pos:], ";)") {
+					depth--
+					p.pos += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					p.advance()
+				}
+			}
+		case strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])):
+			p.advance()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *parser) expr() (*sexpr, error) {
+	start := p.pos
+	e := &sexpr{line: p.line}
+	switch p.src[p.pos] {
+	case '(':
+		e.isList = true
+		p.pos++
+		for {
+			if err := p.skip(); err != nil {
+				return nil, err
+			}
+			if p.pos == len(p.src) {
+				return nil, fmt.Errorf("line %d: unterminated list", e.line)
+			}
+			if p.src[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			child, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			e.list = append(e.list, child)
+		}
+	case ')':
+		return nil, p.errorf("unexpected `)`")
+	case '"':
+		e.isStr = true
+		str, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		e.str = str
+	default:
+		for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n()\";", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		e.atom = p.src[start:p.pos]
+	}
+	e.src = p.src[start:p.pos]
+	return e, nil
+}
+
+// Reads a string literal, decoding its escapes.
+func (p *parser) str() ([]byte, error) {
+	p.pos++
+	var ret []byte
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			return nil, p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		p.pos++
+		if c == '"' {
+			return ret, nil
+		}
+		if c != '\\' {
+			ret = append(ret, c)
+			continue
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated string")
+		}
+		c = p.src[p.pos]
+		p.pos++
+		switch c {
+		case 't':
+			ret = append(ret, '\t')
+		case 'n':
+			ret = append(ret, '\n')
+		case 'r':
+			ret = append(ret, '\r')
+		case '"', '\'', '\\':
+			ret = append(ret, c)
+		case 'u':
+			end := strings.IndexByte(p.src[p.pos:], '}')
+			if !strings.HasPrefix(p.src[p.pos:], "{") || end < 0 {
+				return nil, p.errorf("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(p.src[p.pos+1:p.pos+end], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return nil, p.errorf("invalid unicode escape")
+			}
+			ret = utf8.AppendRune(ret, rune(code))
+			p.pos += end + 1
+		default:
+			if p.pos >= len(p.src) {
+				return nil, p.errorf("unterminated string")
+			}
+			b, err := strconv.ParseUint(p.src[p.pos-1:p.pos+1], 16, 8)
+			if err != nil {
+				return nil, p.errorf("invalid escape `\\%c`", c)
+			}
+			ret = append(ret, byte(b))
+			p.pos++
+		}
+	}
+}
@@ -0,0 +1,66 @@
+;; Exercises each of the directives supported by the runner.
+
+(module $math
+  (func (export "add") (param i32 i32) (result i32)
+    (i32.add (local.get 0) (local.get 1)))
+  (func (export "div_s") (param i64 i64) (result i64)
+    (i64.div_s (local.get 0) (local.get 1)))
+  (func (export "sqrt") (param f32) (result f32)
+    (f32.sqrt (local.get 0)))
+  (func (export "pair") (param f64) (result f64 f64)
+    (local.get 0) (f64.neg (local.get 0)))
+  (func (export "id") (param externref) (result externref)
+    (local.get 0))
+  (func $loop (export "loop") (call $loop))
+  (func (export "unreachable") unreachable)
+  (global (export "g") i32 (i32.const 42))
+)
+
+(assert_return (invoke "add" (i32.const 1) (i32.const 2)) (i32.const 3))
+(assert_return (invoke "add" (i32.const 0xffffffff) (i32.const 1)) (i32.const 0))
+(assert_return (invoke $math "div_s" (i64.const -9) (i64.const 2)) (i64.const -4))
+(assert_return (invoke "sqrt" (f32.const 0x1p2)) (f32.const 2))
+(assert_return (invoke "sqrt" (f32.const -1)) (f32.const nan:canonical))
+(assert_return (invoke "pair" (f64.const 1.5)) (f64.const 1.5) (f64.const -1.5))
+(assert_return (invoke "id" (ref.extern 1)) (ref.extern 1))
+(assert_return (invoke "id" (ref.null extern)) (ref.null extern))
+(assert_return (invoke "add" (i32.const 1) (i32.const 1))
+  (either (i32.const 1) (i32.const 2)))
+(assert_return (get "g") (i32.const 42))
+(invoke "add" (i32.const 1) (i32.const 2))
+
+(assert_trap (invoke "div_s" (i64.const 1) (i64.const 0)) "integer divide by zero")
+(assert_trap (invoke "unreachable") "unreachable")
+(assert_exhaustion (invoke "loop") "call stack exhausted")
+
+(register "math" $math)
+(module
+  (import "math" "add" (func $add (param i32 i32) (result i32)))
+  (import "spectest" "print_i32" (func $print (param i32)))
+  (import "spectest" "global_i32" (global i32))
+  (import "spectest" "memory" (memory 1))
+  (func (export "add_global") (param i32) (result i32)
+    (call $add (local.get 0) (global.get 0)))
+  (func (export "print") (call $print (i32.const 1)))
+)
+(assert_return (invoke "add_global" (i32.const 1)) (i32.const 667))
+(invoke "print")
+
+(module binary
+  "\00asm" "\01\00\00\00"
+)
+(module quote "(func (export \"f\"))")
+(assert_return (invoke "f"))
+
+(assert_invalid
+  (module (func (result i32) (i64.const 0)))
+  "type mismatch")
+(assert_malformed
+  (module quote "(func (i32.const))")
+  "unexpected token")
+(assert_unlinkable
+  (module (import "math" "missing" (func)))
+  "unknown import")
+(assert_trap
+  (module (func $start unreachable) (start $start))
+  "unreachable")
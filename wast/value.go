@@ -0,0 +1,332 @@
+package wast
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bytecodealliance/wasmtime-go/v44"
+)
+
+// Parses an argument of an `invoke`, such as `(i32.const 1)`.
+func parseArg(e *sexpr) (wasmtime.Val, error) {
+	kind, lit, err := constParts(e)
+	if err != nil {
+		return wasmtime.Val{}, err
+	}
+	switch kind {
+	case "i32.const":
+		v, err := parseInt(lit, 32)
+		return wasmtime.ValI32(int32(v)), err
+	case "i64.const":
+		v, err := parseInt(lit, 64)
+		return wasmtime.ValI64(int64(v)), err
+	case "f32.const":
+		v, err := parseF32(lit)
+		return wasmtime.ValF32(math.Float32frombits(v)), err
+	case "f64.const":
+		v, err := parseF64(lit)
+		return wasmtime.ValF64(math.Float64frombits(v)), err
+	case "ref.null":
+		switch lit {
+		case "func":
+			return wasmtime.ValFuncref(nil), nil
+		case "extern":
+			return wasmtime.ValExternref(nil), nil
+		}
+	case "ref.extern":
+		v, err := parseInt(lit, 32)
+		return wasmtime.ValExternref(uint32(v)), err
+	}
+	return wasmtime.Val{}, fmt.Errorf("unsupported argument `%s`", e)
+}
+
+// Returns the instruction and the literal of a constant such as
+// `(i32.const 1)`.
+func constParts(e *sexpr) (string, string, error) {
+	if !e.isList || len(e.list) == 0 || len(e.list) > 2 {
+		return "", "", fmt.Errorf("expected a constant, found `%s`", e)
+	}
+	for _, item := range e.list {
+		if item.isList || item.isStr {
+			return "", "", fmt.Errorf("expected a constant, found `%s`", e)
+		}
+	}
+	if len(e.list) == 1 {
+		return e.list[0].atom, "", nil
+	}
+	return e.list[0].atom, e.list[1].atom, nil
+}
+
+// Parses an integer literal of `bits` bits, which may be written either signed
+// or unsigned.
+func parseInt(lit string, bits int) (uint64, error) {
+	s := strings.ReplaceAll(lit, "_", "")
+	neg := false
+	if strings.HasPrefix(s, "-") {
+		neg = true
+		s = s[1:]
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	base := 10
+	if strings.HasPrefix(s, "0x") {
+		base = 16
+		s = s[2:]
+	}
+	v, err := strconv.ParseUint(s, base, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer `%s`", lit)
+	}
+	if neg {
+		if v > 1<<(bits-1) {
+			return 0, fmt.Errorf("invalid integer `%s`", lit)
+		}
+		v = -v
+	}
+	return v, nil
+}
+
+// Parses a float literal to the bits of an `f32`, including NaNs with
+// payloads such as `nan:0x200000`.
+func parseF32(lit string) (uint32, error) {
+	s, sign := floatSign(lit)
+	if strings.HasPrefix(s, "nan") {
+		payload := uint64(1 << 22)
+		if s != "nan" {
+			var err error
+			payload, err = nanPayload(s, 23)
+			if err != nil {
+				return 0, fmt.Errorf("invalid float `%s`", lit)
+			}
+		}
+		return uint32(sign)<<31 | 0x7f800000 | uint32(payload), nil
+	}
+	v, err := strconv.ParseFloat(floatSyntax(s), 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid float `%s`", lit)
+	}
+	return uint32(sign)<<31 | math.Float32bits(float32(v)), nil
+}
+
+// Parses a float literal to the bits of an `f64`.
+func parseF64(lit string) (uint64, error) {
+	s, sign := floatSign(lit)
+	if strings.HasPrefix(s, "nan") {
+		payload := uint64(1 << 51)
+		if s != "nan" {
+			var err error
+			payload, err = nanPayload(s, 52)
+			if err != nil {
+				return 0, fmt.Errorf("invalid float `%s`", lit)
+			}
+		}
+		return sign<<63 | 0x7ff0000000000000 | payload, nil
+	}
+	v, err := strconv.ParseFloat(floatSyntax(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid float `%s`", lit)
+	}
+	return sign<<63 | math.Float64bits(v), nil
+}
+
+// Splits the sign off a float literal, returning 1 if it's negative.
+func floatSign(lit string) (string, uint64) {
+	s := strings.ReplaceAll(lit, "_", "")
+	if strings.HasPrefix(s, "-") {
+		return s[1:], 1
+	}
+	return strings.TrimPrefix(s, "+"), 0
+}
+
+func nanPayload(s string, bits int) (uint64, error) {
+	if !strings.HasPrefix(s, "nan:0x") {
+		return 0, fmt.Errorf("invalid NaN `%s`", s)
+	}
+	payload, err := strconv.ParseUint(s[len("nan:0x"):], 16, bits)
+	if err != nil || payload == 0 {
+		return 0, fmt.Errorf("invalid NaN `%s`", s)
+	}
+	return payload, nil
+}
+
+// Converts an unsigned float literal of the text format to Go's syntax, which
+// requires an exponent for hexadecimal floats.
+func floatSyntax(s string) string {
+	if strings.HasPrefix(s, "0x") && !strings.ContainsAny(s, "pP") {
+		return s + "p0"
+	}
+	return s
+}
+
+// An expected result of an `assert_return`.
+type expected struct {
+	// the kind of value expected, such as `i32.const`
+	kind string
+	bits uint64
+	// `nan:canonical` or `nan:arithmetic`, or empty for a specific value
+	nan string
+	// for `ref.extern` and `ref.func`, whether any non-null reference matches
+	anyRef bool
+	// alternatives for an `(either ...)` result, in which case the other
+	// fields are unused
+	either []expected
+}
+
+func parseExpected(e *sexpr) (expected, error) {
+	if e.head() == "either" {
+		var ret expected
+		for _, alt := range e.list[1:] {
+			exp, err := parseExpected(alt)
+			if err != nil {
+				return expected{}, err
+			}
+			ret.either = append(ret.either, exp)
+		}
+		return ret, nil
+	}
+	kind, lit, err := constParts(e)
+	if err != nil {
+		return expected{}, err
+	}
+	ret := expected{kind: kind}
+	switch kind {
+	case "i32.const":
+		ret.bits, err = parseInt(lit, 32)
+	case "i64.const":
+		ret.bits, err = parseInt(lit, 64)
+	case "f32.const", "f64.const":
+		if lit == "nan:canonical" || lit == "nan:arithmetic" {
+			ret.nan = lit
+		} else if kind == "f32.const" {
+			var bits uint32
+			bits, err = parseF32(lit)
+			ret.bits = uint64(bits)
+		} else {
+			ret.bits, err = parseF64(lit)
+		}
+	case "ref.null":
+		if lit != "func" && lit != "extern" {
+			err = fmt.Errorf("unsupported result `%s`", e)
+		}
+	case "ref.extern":
+		if lit == "" {
+			ret.anyRef = true
+		} else {
+			ret.bits, err = parseInt(lit, 32)
+		}
+	case "ref.func":
+		ret.anyRef = true
+	default:
+		err = fmt.Errorf("unsupported result `%s`", e)
+	}
+	return ret, err
+}
+
+// Returns whether `val` matches this expected result.
+func (exp expected) matches(val wasmtime.Val) bool {
+	if exp.either != nil {
+		for _, alt := range exp.either {
+			if alt.matches(val) {
+				return true
+			}
+		}
+		return false
+	}
+	switch exp.kind {
+	case "i32.const":
+		return val.Kind() == wasmtime.KindI32 && uint32(val.I32()) == uint32(exp.bits)
+	case "i64.const":
+		return val.Kind() == wasmtime.KindI64 && uint64(val.I64()) == exp.bits
+	case "f32.const":
+		if val.Kind() != wasmtime.KindF32 {
+			return false
+		}
+		bits := math.Float32bits(val.F32())
+		switch exp.nan {
+		case "nan:canonical":
+			return bits&0x7fffffff == 0x7fc00000
+		case "nan:arithmetic":
+			return bits&0x7fc00000 == 0x7fc00000
+		}
+		return bits == uint32(exp.bits)
+	case "f64.const":
+		if val.Kind() != wasmtime.KindF64 {
+			return false
+		}
+		bits := math.Float64bits(val.F64())
+		switch exp.nan {
+		case "nan:canonical":
+			return bits&0x7fffffffffffffff == 0x7ff8000000000000
+		case "nan:arithmetic":
+			return bits&0x7ff8000000000000 == 0x7ff8000000000000
+		}
+		return bits == exp.bits
+	case "ref.null":
+		switch val.Kind() {
+		case wasmtime.KindFuncref:
+			return val.Funcref() == nil
+		case wasmtime.KindExternref:
+			return val.Externref() == nil
+		}
+	case "ref.extern":
+		if val.Kind() != wasmtime.KindExternref || val.Externref() == nil {
+			return false
+		}
+		return exp.anyRef || val.Externref() == uint32(exp.bits)
+	case "ref.func":
+		return val.Kind() == wasmtime.KindFuncref && val.Funcref() != nil
+	}
+	return false
+}
+
+func (exp expected) String() string {
+	if exp.either != nil {
+		alts := make([]string, len(exp.either))
+		for i, alt := range exp.either {
+			alts[i] = alt.String()
+		}
+		return "(either " + strings.Join(alts, " ") + ")"
+	}
+	switch {
+	case exp.nan != "":
+		return fmt.Sprintf("(%s %s)", exp.kind, exp.nan)
+	case exp.anyRef, exp.kind == "ref.null":
+		return "(" + exp.kind + ")"
+	case exp.kind == "f32.const":
+		return fmt.Sprintf("(f32.const %v)", math.Float32frombits(uint32(exp.bits)))
+	case exp.kind == "f64.const":
+		return fmt.Sprintf("(f64.const %v)", math.Float64frombits(exp.bits))
+	case exp.kind == "i32.const":
+		return fmt.Sprintf("(i32.const %d)", int32(exp.bits))
+	case exp.kind == "i64.const":
+		return fmt.Sprintf("(i64.const %d)", int64(exp.bits))
+	}
+	return fmt.Sprintf("(%s %d)", exp.kind, exp.bits)
+}
+
+// Formats `val` in the same syntax as expected results.
+func formatVal(val wasmtime.Val) string {
+	switch val.Kind() {
+	case wasmtime.KindI32:
+		return fmt.Sprintf("(i32.const %d)", val.I32())
+	case wasmtime.KindI64:
+		return fmt.Sprintf("(i64.const %d)", val.I64())
+	case wasmtime.KindF32:
+		return fmt.Sprintf("(f32.const %v (;0x%x;))", val.F32(), math.Float32bits(val.F32()))
+	case wasmtime.KindF64:
+		return fmt.Sprintf("(f64.const %v (;0x%x;))", val.F64(), math.Float64bits(val.F64()))
+	case wasmtime.KindFuncref:
+		if val.Funcref() == nil {
+			return "(ref.null func)"
+		}
+		return "(ref.func)"
+	case wasmtime.KindExternref:
+		if val.Externref() == nil {
+			return "(ref.null extern)"
+		}
+		return fmt.Sprintf("(ref.extern %v)", val.Externref())
+	}
+	return val.Kind().String()
+}
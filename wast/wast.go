@@ -0,0 +1,463 @@
+// Package wast runs WebAssembly scripts, the `.wast` files of the WebAssembly
+// specification test suite, against the wasmtime package.
+//
+// Each directive of a script is executed in order through a `Linker`, and any
+// directive which fails is reported as a failure of the Go test running the
+// script, along with the line of the script it's on:
+//
+//	func TestSpec(t *testing.T) {
+//		runner, err := wast.NewRunner(wasmtime.NewEngine())
+//		require.NoError(t, err)
+//		runner.RunFile(t, "testdata/i32.wast")
+//	}
+//
+// The supported directives are `module`, `register`, `invoke`, `get`,
+// `assert_return`, `assert_trap`, `assert_exhaustion`, `assert_invalid`,
+// `assert_malformed`, `assert_unlinkable` and `assert_uninstantiable`.
+// Scripts using SIMD values or other unsupported constructs fail the
+// directives using them.
+package wast
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go/v44"
+)
+
+// Runner executes WebAssembly scripts.
+//
+// The instances of all the scripts run by a runner share its `Store`, and
+// modules registered by a script can be imported by those run after it.
+type Runner struct {
+	// Store is the store modules are instantiated within.
+	Store *wasmtime.Store
+	// Linker is the linker imports are resolved with, which can be used to
+	// define custom host functions for scripts to import.
+	Linker *wasmtime.Linker
+
+	// the most recently instantiated module, and instances by their
+	// identifiers
+	current   *wasmtime.Instance
+	instances map[string]*wasmtime.Instance
+}
+
+// NewRunner creates a new `Runner` with a fresh store within `engine`.
+//
+// The `spectest` module, which the specification test suite imports from, is
+// defined within the runner's linker.
+func NewRunner(engine *wasmtime.Engine) (*Runner, error) {
+	r := &Runner{
+		Store:     wasmtime.NewStore(engine),
+		Linker:    wasmtime.NewLinker(engine),
+		instances: make(map[string]*wasmtime.Instance),
+	}
+	// Scripts commonly register modules under the same name more than once.
+	r.Linker.AllowShadowing(true)
+	if err := r.defineSpectest(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Defines the `spectest` module, as described by the specification's test
+// suite.
+func (r *Runner) defineSpectest() error {
+	prints := map[string]interface{}{
+		"print":         func() {},
+		"print_i32":     func(int32) {},
+		"print_i64":     func(int64) {},
+		"print_f32":     func(float32) {},
+		"print_f64":     func(float64) {},
+		"print_i32_f32": func(int32, float32) {},
+		"print_f64_f64": func(float64, float64) {},
+	}
+	for name, f := range prints {
+		if err := r.Linker.FuncWrap("spectest", name, f); err != nil {
+			return err
+		}
+	}
+
+	globals := []struct {
+		name string
+		val  wasmtime.Val
+	}{
+		{"global_i32", wasmtime.ValI32(666)},
+		{"global_i64", wasmtime.ValI64(666)},
+		{"global_f32", wasmtime.ValF32(666.6)},
+		{"global_f64", wasmtime.ValF64(666.6)},
+	}
+	for _, g := range globals {
+		ty := wasmtime.NewGlobalType(wasmtime.NewValType(g.val.Kind()), false)
+		global, err := wasmtime.NewGlobal(r.Store, ty, g.val)
+		if err != nil {
+			return err
+		}
+		if err := r.Linker.Define(r.Store, "spectest", g.name, global); err != nil {
+			return err
+		}
+	}
+
+	tableTy := wasmtime.NewTableType(wasmtime.NewValType(wasmtime.KindFuncref), 10, true, 20)
+	table, err := wasmtime.NewTable(r.Store, tableTy, wasmtime.ValFuncref(nil))
+	if err != nil {
+		return err
+	}
+	if err := r.Linker.Define(r.Store, "spectest", "table", table); err != nil {
+		return err
+	}
+
+	memoryTy, err := wasmtime.NewMemoryType(1, true, 2, false)
+	if err != nil {
+		return err
+	}
+	memory, err := wasmtime.NewMemory(r.Store, memoryTy)
+	if err != nil {
+		return err
+	}
+	return r.Linker.Define(r.Store, "spectest", "memory", memory)
+}
+
+// RunFile runs the script at `path`, reporting the directives which fail to
+// `t`.
+func (r *Runner) RunFile(t testing.TB, path string) {
+	t.Helper()
+	script, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Run(t, path, string(script))
+}
+
+// Run runs `script`, reporting the directives which fail to `t`. The `name`
+// of the script is used to identify the failing directives.
+func (r *Runner) Run(t testing.TB, name, script string) {
+	t.Helper()
+	directives, err := parse(script)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	for _, d := range directives {
+		if err := r.directive(d); err != nil {
+			t.Errorf("%s:%d: %v", name, d.line, err)
+		}
+	}
+}
+
+func (r *Runner) directive(d *sexpr) error {
+	switch d.head() {
+	case "module":
+		id, module, err := r.compile(d)
+		if err != nil {
+			return err
+		}
+		instance, err := r.Linker.Instantiate(r.Store, module)
+		if err != nil {
+			return fmt.Errorf("failed to instantiate module: %w", err)
+		}
+		r.current = instance
+		if id != "" {
+			r.instances[id] = instance
+		}
+		return nil
+
+	case "register":
+		if len(d.list) < 2 || !d.list[1].isStr || len(d.list) > 3 {
+			return fmt.Errorf("malformed `register`")
+		}
+		id := ""
+		if len(d.list) == 3 {
+			id = d.list[2].atom
+		}
+		instance, err := r.instance(id)
+		if err != nil {
+			return err
+		}
+		return r.Linker.DefineInstance(r.Store, string(d.list[1].str), instance)
+
+	case "invoke", "get":
+		call, err := r.action(d)
+		if err != nil {
+			return err
+		}
+		_, err = call()
+		return err
+
+	case "assert_return":
+		if len(d.list) < 2 {
+			return fmt.Errorf("malformed `assert_return`")
+		}
+		call, err := r.action(d.list[1])
+		if err != nil {
+			return err
+		}
+		var expects []expected
+		for _, e := range d.list[2:] {
+			exp, err := parseExpected(e)
+			if err != nil {
+				return err
+			}
+			expects = append(expects, exp)
+		}
+		results, err := call()
+		if err != nil {
+			return fmt.Errorf("unexpected error: %w", err)
+		}
+		if len(results) != len(expects) {
+			return fmt.Errorf("expected %d results, got %d", len(expects), len(results))
+		}
+		for i, result := range results {
+			if !expects[i].matches(result) {
+				return fmt.Errorf("result %d: expected %s, got %s", i, expects[i], formatVal(result))
+			}
+		}
+		return nil
+
+	case "assert_trap", "assert_uninstantiable":
+		message, err := assertMessage(d)
+		if err != nil {
+			return err
+		}
+		if d.list[1].head() == "module" {
+			_, module, err := r.compile(d.list[1])
+			if err != nil {
+				return err
+			}
+			_, err = r.Linker.Instantiate(r.Store, module)
+			return expectTrap(err, message)
+		}
+		call, err := r.action(d.list[1])
+		if err != nil {
+			return err
+		}
+		_, err = call()
+		return expectTrap(err, message)
+
+	case "assert_exhaustion":
+		if _, err := assertMessage(d); err != nil {
+			return err
+		}
+		call, err := r.action(d.list[1])
+		if err != nil {
+			return err
+		}
+		_, err = call()
+		if err == nil {
+			return errors.New("expected stack exhaustion, but the call returned")
+		}
+		if !errors.Is(err, wasmtime.ErrStackOverflow) {
+			return fmt.Errorf("expected stack exhaustion, got: %w", err)
+		}
+		return nil
+
+	case "assert_invalid", "assert_malformed":
+		message, err := assertMessage(d)
+		if err != nil {
+			return err
+		}
+		// Error messages aren't compared, as Wasmtime's validation errors are
+		// worded differently from those of the reference interpreter.
+		if _, _, err := r.compile(d.list[1]); err == nil {
+			return fmt.Errorf("expected module to fail to compile with %q", message)
+		}
+		return nil
+
+	case "assert_unlinkable":
+		message, err := assertMessage(d)
+		if err != nil {
+			return err
+		}
+		_, module, err := r.compile(d.list[1])
+		if err != nil {
+			return err
+		}
+		if _, err := r.Linker.Instantiate(r.Store, module); err == nil {
+			return fmt.Errorf("expected module to fail to link with %q", message)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported directive `%s`", d.head())
+}
+
+// Returns the message of an assertion of the form `(assert_x item "message")`.
+func assertMessage(d *sexpr) (string, error) {
+	if len(d.list) != 3 || !d.list[1].isList || !d.list[2].isStr {
+		return "", fmt.Errorf("malformed `%s`", d.head())
+	}
+	return string(d.list[2].str), nil
+}
+
+// Compiles a `(module ...)`, returning its identifier if it has one.
+func (r *Runner) compile(d *sexpr) (string, *wasmtime.Module, error) {
+	if d.head() != "module" {
+		return "", nil, fmt.Errorf("expected a module, found `%s`", d)
+	}
+	id := ""
+	rest := d.list[1:]
+	if len(rest) > 0 && rest[0].isID() {
+		id = rest[0].atom
+		rest = rest[1:]
+	}
+
+	var wasm []byte
+	if len(rest) > 0 && (rest[0].atom == "binary" || rest[0].atom == "quote") {
+		var contents []byte
+		for _, s := range rest[1:] {
+			if !s.isStr {
+				return "", nil, fmt.Errorf("expected a string, found `%s`", s)
+			}
+			contents = append(contents, s.str...)
+		}
+		if rest[0].atom == "binary" {
+			wasm = contents
+		} else {
+			var err error
+			if wasm, err = wasmtime.Wat2Wasm("(module " + string(contents) + ")"); err != nil {
+				return "", nil, err
+			}
+		}
+	} else if len(rest) > 0 && (rest[0].atom == "definition" || rest[0].atom == "instance") {
+		return "", nil, fmt.Errorf("unsupported module form `%s`", rest[0].atom)
+	} else {
+		var err error
+		if wasm, err = wasmtime.Wat2Wasm(d.src); err != nil {
+			return "", nil, err
+		}
+	}
+
+	module, err := wasmtime.NewModule(r.Store.Engine, wasm)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, module, nil
+}
+
+// Returns the instance with the identifier `id`, or the most recently
+// instantiated one if `id` is empty.
+func (r *Runner) instance(id string) (*wasmtime.Instance, error) {
+	if id == "" {
+		if r.current == nil {
+			return nil, errors.New("no module has been instantiated")
+		}
+		return r.current, nil
+	}
+	instance, ok := r.instances[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown module `%s`", id)
+	}
+	return instance, nil
+}
+
+// Resolves an `(invoke ...)` or `(get ...)` action, returning a function
+// which performs it. Errors resolving the action are returned here, while
+// errors, such as traps, of the action itself are returned by the function.
+func (r *Runner) action(a *sexpr) (func() ([]wasmtime.Val, error), error) {
+	kind := a.head()
+	if kind != "invoke" && kind != "get" {
+		return nil, fmt.Errorf("expected an action, found `%s`", a)
+	}
+	rest := a.list[1:]
+	id := ""
+	if len(rest) > 0 && rest[0].isID() {
+		id = rest[0].atom
+		rest = rest[1:]
+	}
+	if len(rest) == 0 || !rest[0].isStr {
+		return nil, fmt.Errorf("malformed `%s`", kind)
+	}
+	name := string(rest[0].str)
+	instance, err := r.instance(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == "get" {
+		if len(rest) != 1 {
+			return nil, fmt.Errorf("malformed `get`")
+		}
+		export := instance.GetExport(r.Store, name)
+		if export == nil || export.Global() == nil {
+			return nil, fmt.Errorf("no global exported as %q", name)
+		}
+		return func() ([]wasmtime.Val, error) {
+			return []wasmtime.Val{export.Global().Get(r.Store)}, nil
+		}, nil
+	}
+
+	f := instance.GetFunc(r.Store, name)
+	if f == nil {
+		return nil, fmt.Errorf("no function exported as %q", name)
+	}
+	var args []interface{}
+	for _, e := range rest[1:] {
+		arg, err := parseArg(e)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return func() ([]wasmtime.Val, error) {
+		ret, err := f.Call(r.Store, args...)
+		if err != nil {
+			return nil, err
+		}
+		return callResults(f.Type(r.Store).Results(), ret), nil
+	}, nil
+}
+
+// Converts the result of `Func.Call` to a list of values of the `types`
+// provided.
+func callResults(types []*wasmtime.ValType, ret interface{}) []wasmtime.Val {
+	switch len(types) {
+	case 0:
+		return nil
+	case 1:
+		switch types[0].Kind() {
+		case wasmtime.KindI32:
+			return []wasmtime.Val{wasmtime.ValI32(ret.(int32))}
+		case wasmtime.KindI64:
+			return []wasmtime.Val{wasmtime.ValI64(ret.(int64))}
+		case wasmtime.KindF32:
+			return []wasmtime.Val{wasmtime.ValF32(ret.(float32))}
+		case wasmtime.KindF64:
+			return []wasmtime.Val{wasmtime.ValF64(ret.(float64))}
+		case wasmtime.KindFuncref:
+			f, _ := ret.(*wasmtime.Func)
+			return []wasmtime.Val{wasmtime.ValFuncref(f)}
+		default:
+			return []wasmtime.Val{wasmtime.ValExternref(ret)}
+		}
+	}
+	return ret.([]wasmtime.Val)
+}
+
+// Aliases of the trap messages used by the specification's test suite for
+// Wasmtime's traps, whose messages are worded differently.
+var trapAliases = map[wasmtime.TrapCode][]string{
+	wasmtime.TableOutOfBounds:       {"undefined element", "out of bounds table access"},
+	wasmtime.IndirectCallToNull:     {"uninitialized element"},
+	wasmtime.HeapMisaligned:         {"unaligned atomic"},
+	wasmtime.UnreachableCodeReached: {"unreachable"},
+}
+
+// Checks that `err` is a trap with the `message` expected.
+func expectTrap(err error, message string) error {
+	if err == nil {
+		return fmt.Errorf("expected trap %q, but no trap happened", message)
+	}
+	if strings.Contains(err.Error(), message) {
+		return nil
+	}
+	var trap *wasmtime.Trap
+	if errors.As(err, &trap) && trap.Code() != nil {
+		for _, alias := range trapAliases[*trap.Code()] {
+			if strings.HasPrefix(message, alias) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("expected trap %q, got: %w", message, err)
+}
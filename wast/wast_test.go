@@ -0,0 +1,52 @@
+package wast
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go/v44"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunFile(t *testing.T) {
+	runner, err := NewRunner(wasmtime.NewEngine())
+	require.NoError(t, err)
+	runner.RunFile(t, "testdata/basic.wast")
+}
+
+// Records the failures reported by a runner.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestRunFailures(t *testing.T) {
+	runner, err := NewRunner(wasmtime.NewEngine())
+	require.NoError(t, err)
+	rec := &recorder{TB: t}
+	runner.RunFile(rec, "testdata/failures.wast")
+	require.Len(t, rec.failures, 4)
+	require.Contains(t, rec.failures[0], "testdata/failures.wast:4: result 0: expected (i32.const 2), got (i32.const 1)")
+	require.Contains(t, rec.failures[1], "testdata/failures.wast:5: expected trap \"unreachable\"")
+	require.Contains(t, rec.failures[2], "testdata/failures.wast:6: expected module to fail to compile")
+	require.Contains(t, rec.failures[3], "testdata/failures.wast:7: no function exported as \"missing\"")
+}
+
+func TestRunCustomHost(t *testing.T) {
+	runner, err := NewRunner(wasmtime.NewEngine())
+	require.NoError(t, err)
+	require.NoError(t, runner.Linker.FuncWrap("host", "double", func(x int32) int32 { return x * 2 }))
+	runner.Run(t, "host.wast", `
+	  (module
+	    (import "host" "double" (func $double (param i32) (result i32)))
+	    (func (export "quadruple") (param i32) (result i32)
+	      (call $double (call $double (local.get 0)))))
+	  (assert_return (invoke "quadruple" (i32.const 3)) (i32.const 12))
+	`)
+}
@@ -11,6 +11,9 @@ import (
 //
 // Takes the text format in-memory as input, and returns either the binary
 // encoding of the text format or an error if parsing fails.
+//
+// The inverse of this function is provided by the separate `wasm2wat` package,
+// as the C API doesn't expose one.
 func Wat2Wasm(wat string) ([]byte, error) {
 	retVec := C.wasm_byte_vec_t{}
 	err := C.wasmtime_wat2wasm(